@@ -1,7 +1,9 @@
 module github.com/ashald/terraform-provider-stateful
 
 require (
+	github.com/agext/levenshtein v1.2.2
 	github.com/hashicorp/terraform v0.12.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/terraform-providers/terraform-provider-null v1.0.0
+	golang.org/x/text v0.3.2
 )
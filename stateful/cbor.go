@@ -0,0 +1,165 @@
+package stateful
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+const (
+	cborMajorUnsigned = 0
+	cborMajorNegative = 1
+	cborMajorText     = 3
+	cborMajorArray    = 4
+	cborMajorMap      = 5
+	cborMajorSimple   = 7
+)
+
+// getCBOR encodes the value as deterministic CBOR (RFC 8949, section 4.2.1): integers and lengths use the shortest
+// form, floats use the shortest form that preserves their value and map entries are sorted by the bytewise lexical
+// order of their encoded keys
+func getCBOR(o interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeCBOR(&b, reflect.ValueOf(o)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeCBOR(b *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		b.WriteByte(0xf6) // null
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			b.WriteByte(0xf6)
+			return nil
+		}
+		return writeCBOR(b, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			b.WriteByte(0xf5)
+		} else {
+			b.WriteByte(0xf4)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i < 0 {
+			writeCBORHead(b, cborMajorNegative, uint64(-(i + 1)))
+		} else {
+			writeCBORHead(b, cborMajorUnsigned, uint64(i))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		writeCBORHead(b, cborMajorUnsigned, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeCBORFloat(b, v.Float())
+	case reflect.String:
+		writeCBORHead(b, cborMajorText, uint64(v.Len()))
+		b.WriteString(v.String())
+	case reflect.Slice, reflect.Array:
+		writeCBORHead(b, cborMajorArray, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := writeCBOR(b, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		entries := make([][2][]byte, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			var k, e bytes.Buffer
+			if err := writeCBOR(&k, key); err != nil {
+				return err
+			}
+			if err := writeCBOR(&e, v.MapIndex(key)); err != nil {
+				return err
+			}
+			entries = append(entries, [2][]byte{k.Bytes(), e.Bytes()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i][0], entries[j][0]) < 0 })
+
+		writeCBORHead(b, cborMajorMap, uint64(len(entries)))
+		for _, entry := range entries {
+			b.Write(entry[0])
+			b.Write(entry[1])
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// writeCBORHead writes the initial byte of a data item followed by its argument in the shortest form
+func writeCBORHead(b *bytes.Buffer, major byte, argument uint64) {
+	major <<= 5
+	switch {
+	case argument < 24:
+		b.WriteByte(major | byte(argument))
+	case argument <= math.MaxUint8:
+		b.WriteByte(major | 24)
+		b.WriteByte(byte(argument))
+	case argument <= math.MaxUint16:
+		b.WriteByte(major | 25)
+		binary.Write(b, binary.BigEndian, uint16(argument))
+	case argument <= math.MaxUint32:
+		b.WriteByte(major | 26)
+		binary.Write(b, binary.BigEndian, uint32(argument))
+	default:
+		b.WriteByte(major | 27)
+		binary.Write(b, binary.BigEndian, argument)
+	}
+}
+
+func writeCBORFloat(b *bytes.Buffer, f float64) {
+	if math.IsNaN(f) {
+		b.Write([]byte{cborMajorSimple<<5 | 25, 0x7e, 0x00}) // canonical NaN
+		return
+	}
+	if half, ok := getFloat16(f); ok {
+		b.WriteByte(cborMajorSimple<<5 | 25)
+		binary.Write(b, binary.BigEndian, half)
+		return
+	}
+	if float64(float32(f)) == f {
+		b.WriteByte(cborMajorSimple<<5 | 26)
+		binary.Write(b, binary.BigEndian, math.Float32bits(float32(f)))
+		return
+	}
+	b.WriteByte(cborMajorSimple<<5 | 27)
+	binary.Write(b, binary.BigEndian, math.Float64bits(f))
+}
+
+// getFloat16 converts the value to IEEE 754 half precision when this can be done without loss of precision
+func getFloat16(f float64) (uint16, bool) {
+	if float64(float32(f)) != f {
+		return 0, false
+	}
+	bits := math.Float32bits(float32(f))
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23&0xff) - 127
+	mantissa := bits & 0x7fffff
+
+	switch {
+	case bits&0x7fffffff == 0: // zero
+		return sign, true
+	case exponent == 128: // infinity, NaN is handled separately
+		return sign | 0x7c00, true
+	case exponent >= -14 && exponent <= 15: // normal
+		if mantissa&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(exponent+15)<<10 | uint16(mantissa>>13), true
+	case exponent >= -24 && exponent < -14: // subnormal
+		shift := uint(-exponent - 14 + 13)
+		full := mantissa | 0x800000
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
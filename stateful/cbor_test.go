@@ -0,0 +1,86 @@
+package stateful
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestCBOR(t *testing.T) {
+	// Test vectors from RFC 8949, Appendix A
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{1000000, "1a000f4240"},
+		{1000000000000, "1b000000e8d4a51000"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{0.0, "f90000"},
+		{1.5, "f93e00"},
+		{65504.0, "f97bff"},
+		{100000.0, "fa47c35000"},
+		{1.1, "fb3ff199999999999a"},
+		{5.960464477539063e-8, "f90001"},
+		{math.Inf(1), "f97c00"},
+		{math.NaN(), "f97e00"},
+		{false, "f4"},
+		{true, "f5"},
+		{nil, "f6"},
+		{"", "60"},
+		{"IETF", "6449455446"},
+		{"ü", "62c3bc"},
+		{[]interface{}{1, []interface{}{2, 3}, []int{4, 5}}, "8301820203820405"},
+		{map[string]interface{}{"a": 1, "b": []interface{}{2, 3}}, "a26161016162820203"},
+		// keys are sorted by their encoded form, so shorter keys go first
+		{map[string]interface{}{"aa": "x", "b": "y"}, "a2616261796261616178"},
+	}
+	for _, c := range cases {
+		encoded, err := getCBOR(c.value)
+		if err != nil {
+			t.Fatalf("getCBOR(%#v) failed: %s", c.value, err)
+		}
+		if actual := hex.EncodeToString(encoded); actual != c.expected {
+			t.Errorf("getCBOR(%#v) = %s, expected %s", c.value, actual, c.expected)
+		}
+	}
+}
+
+const templateSerialization = `
+provider "stateful" {
+  serialization = "cbor"
+}
+resource "stateful_string" "string" {
+  desired = "foo"
+}
+resource "stateful_map" "map" {
+  desired = {
+    b = "2"
+    a = "1"
+  }
+}
+`
+
+func TestProviderSerializationCBOR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateSerialization,
+				Check: resource.ComposeTestCheckFunc(
+					// sha256(bytes.fromhex("63666f6f"))
+					testResourceAttrEquals("stateful_string.string", "hash", strPtr("a45584242a16080d9712cc1c94743296178f74948522e8ace3e070845cb16c9d")),
+					// sha256(bytes.fromhex("a26161613161626132"))
+					testResourceAttrEquals("stateful_map.map", "hash", strPtr("d21fa619e12eab651a8152677cdabfb8e4e09c8ac393d2c5087496e5ab174925")),
+				),
+			},
+		},
+	})
+}
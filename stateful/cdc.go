@@ -0,0 +1,53 @@
+package stateful
+
+// Content-defined chunking parameters: boundaries are found with a rolling hash over a sliding window so that an edit
+// only affects the chunks around it, chunks are 256 bytes long on average
+const cdcWindowSize = 16
+const cdcMinChunkSize = 64
+const cdcMaxChunkSize = 1024
+const cdcBoundaryBits = 8
+
+// cdcBase is the base of the Rabin-Karp polynomial rolling hash, arithmetic is modulo 2^64
+const cdcBase = 0x100000001b3
+
+// getChunks splits the value into content-defined chunks. A boundary is placed after a byte whenever the mixed
+// rolling hash of the preceding window has its top bits unset, subject to the minimum and maximum chunk sizes.
+func getChunks(value string) []string {
+	// cdcBase raised to the window size is used to remove the byte leaving the window
+	var outFactor uint64 = 1
+	for i := 0; i < cdcWindowSize; i++ {
+		outFactor *= cdcBase
+	}
+
+	var chunks []string
+	start := 0
+	var h uint64
+	for i := 0; i < len(value); i++ {
+		h = h*cdcBase + uint64(value[i]) + 1
+		if i-start >= cdcWindowSize {
+			h -= outFactor * (uint64(value[i-cdcWindowSize]) + 1)
+		}
+
+		size := i - start + 1
+		boundary := size >= cdcMinChunkSize && (h*0x9e3779b97f4a7c15)>>(64-cdcBoundaryBits) == 0
+		if boundary || size >= cdcMaxChunkSize {
+			chunks = append(chunks, value[start:i+1])
+			start = i + 1
+			h = 0
+		}
+	}
+	if start < len(value) {
+		chunks = append(chunks, value[start:])
+	}
+	return chunks
+}
+
+// getChunkHashes hashes every content-defined chunk of the value
+func getChunkHashes(value string) []interface{} {
+	chunks := getChunks(value)
+	hashes := make([]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		hashes[i] = getDigest([]byte(chunk))
+	}
+	return hashes
+}
@@ -0,0 +1,94 @@
+package stateful
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+// getSampleText generates deterministic text of roughly the given size
+func getSampleText(size int) string {
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliett"}
+	random := rand.New(rand.NewSource(42))
+
+	var b strings.Builder
+	for b.Len() < size {
+		b.WriteString(words[random.Intn(len(words))])
+		if random.Intn(8) == 0 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+	return b.String()
+}
+
+func TestChunks(t *testing.T) {
+	text := getSampleText(64 * 1024)
+	chunks := getChunks(text)
+	if strings.Join(chunks, "") != text {
+		t.Fatal("chunks do not add up to the original value")
+	}
+	for i, chunk := range chunks {
+		if len(chunk) > cdcMaxChunkSize || len(chunk) < cdcMinChunkSize && i != len(chunks)-1 {
+			t.Errorf("chunk %d has unexpected size %d", i, len(chunk))
+		}
+	}
+	if len(chunks) < 64 {
+		t.Fatalf("expected at least 64 chunks, got %d", len(chunks))
+	}
+
+	middle := len(text) / 2
+	edited := text[:middle] + "inserted text" + text[middle:]
+
+	before := map[string]bool{}
+	for _, hash := range getChunkHashes(text) {
+		before[hash.(string)] = true
+	}
+	changed := 0
+	for _, hash := range getChunkHashes(edited) {
+		if !before[hash.(string)] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 3 {
+		t.Errorf("expected insertion to change between 1 and 3 chunks, got %d", changed)
+	}
+}
+
+const templateCdcFingerprint = `
+resource "stateful_string" "object" {
+  desired         = <<EOT
+%sEOT
+  cdc_fingerprint = %t
+}
+`
+
+func TestStatefulStringCdcFingerprint(t *testing.T) {
+	text := getSampleText(4*1024) + "\n" // heredoc closing marker has to start a line
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateCdcFingerprint, text, false),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "chunk_count", strPtr("0")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateCdcFingerprint, text, true),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(text))),
+					testResourceAttrEquals("stateful_string.object", "chunk_count", strPtr(strconv.Itoa(len(getChunks(text))))),
+					testResourceAttrEquals("stateful_string.object", "chunk_hashes.0", strPtr(getChunkHashes(text)[0].(string))),
+				),
+			},
+		},
+	})
+}
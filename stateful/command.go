@@ -0,0 +1,105 @@
+package stateful
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// commandCache executes external commands at most once per unique command line and memoizes their output
+type commandCache struct {
+	mutex   sync.Mutex
+	results map[string]*commandResult
+}
+
+type commandResult struct {
+	once   sync.Once
+	output []byte
+	err    error
+}
+
+func newCommandCache() *commandCache {
+	return &commandCache{results: make(map[string]*commandResult)}
+}
+
+func (c *commandCache) run(command []string) ([]byte, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("command must not be empty")
+	}
+
+	key := strings.Join(command, "\x00")
+
+	c.mutex.Lock()
+	result, ok := c.results[key]
+	if !ok {
+		result = &commandResult{}
+		c.results[key] = result
+	}
+	c.mutex.Unlock()
+
+	// Concurrent callers for the same command wait for the single invocation to complete
+	result.once.Do(func() {
+		result.output, result.err = runCommand(command)
+	})
+
+	return result.output, result.err
+}
+
+func runCommand(command []string) ([]byte, error) {
+	return runCommandWithInput(command, "")
+}
+
+// runCommandWithInput executes the command feeding the input to its stdin
+func runCommandWithInput(command []string, input string) ([]byte, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("command %q failed: %s: %s", command, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("command %q failed: %s", command, err)
+	}
+	return output, nil
+}
+
+// runCommandWithLimit executes the command and fails when its output exceeds the limit, the output is drained anyway
+// so that the command doesn't block on a full pipe
+func runCommandWithLimit(command []string, limit int) ([]byte, error) {
+	stdout := &limitedBuffer{limit: limit}
+	var stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("command %q failed: %s: %s", command, err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("command %q failed: %s", command, err)
+	}
+	if stdout.exceeded {
+		return nil, fmt.Errorf("command %q output exceeds %d bytes", command, limit)
+	}
+	return stdout.buffer.Bytes(), nil
+}
+
+// limitedBuffer keeps up to limit bytes written to it and discards the rest. It doesn't embed bytes.Buffer as its
+// ReadFrom would bypass the limit when the output is copied to it.
+type limitedBuffer struct {
+	buffer   bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buffer.Len(); len(p) > room {
+		b.exceeded = true
+		if room > 0 {
+			b.buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buffer.Write(p)
+}
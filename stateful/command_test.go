@@ -0,0 +1,47 @@
+package stateful
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stateful")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	counter := filepath.Join(dir, "counter")
+	script := "echo invoked >> " + counter + "; echo $0"
+
+	cache := newCommandCache()
+	for _, name := range []string{"foo", "foo", "bar", "foo", "bar"} {
+		output, err := cache.run([]string{"sh", "-c", script, name})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if strings.TrimSpace(string(output)) != name {
+			t.Fatalf("command output '%s' does not match expected '%s'", output, name)
+		}
+	}
+
+	invocations, err := ioutil.ReadFile(counter)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Command should be invoked once per unique command line
+	if count := strings.Count(string(invocations), "invoked"); count != 2 {
+		t.Fatalf("command was invoked %d times instead of 2", count)
+	}
+}
+
+func TestCommandCacheError(t *testing.T) {
+	_, err := newCommandCache().run([]string{"sh", "-c", "echo oops >&2; exit 1"})
+	if err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Fatalf("error '%v' should contain command's stderr", err)
+	}
+}
@@ -0,0 +1,129 @@
+package stateful
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+const FieldFailOnDrift = "fail_on_drift"
+const FieldHmacKey = "hmac_key"
+const FieldSerialization = "serialization"
+const FieldHashAlgorithm = "hash_algorithm"
+const FieldIdFromHash = "id_from_hash"
+const FieldHashEncoding = "hash_encoding"
+const FieldSalt = "salt"
+const FieldWarnOnDrift = "warn_on_drift"
+const FieldRetainReal = "retain_real"
+const FieldStrictHashCheck = "strict_hash_check"
+
+const SerializationJson = "json"
+const SerializationCbor = "cbor"
+const SerializationRaw = "raw"
+
+const HashEncodingHex = "hex"
+const HashEncodingBase64url = "base64url"
+
+const HashAlgorithmSha256 = "sha256"
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	HashAlgorithmSha256: sha256.New,
+	"sha1":              sha1.New,
+	"md5":               md5.New,
+	"sha512":            sha512.New,
+}
+
+// Config holds provider-level settings shared by all resources via the meta argument
+type Config struct {
+	FailOnDrift bool
+	// WarnOnDrift makes drift detected upon plan logged as a warning
+	WarnOnDrift bool
+	// RetainReal makes real values stored in the state even when they match desired ones
+	RetainReal bool
+	// StrictHashCheck makes stored hashes that don't match the state fail the refresh instead of being repaired
+	StrictHashCheck bool
+	HmacKey         string
+	// Serialization is the format values are encoded with before hashing
+	Serialization string
+	// HashAlgorithm is the digest fingerprints are computed with
+	HashAlgorithm string
+	// HashEncoding is the encoding fingerprint digests are rendered with
+	HashEncoding string
+	// Salt is prepended to serialized values before they are fingerprinted
+	Salt string
+	// IdFromHash makes new resources use their hash as the id instead of a random UUID
+	IdFromHash bool
+
+	// commands memoizes external commands output for the lifetime of the provider process (a single plan or apply)
+	commands *commandCache
+}
+
+func newConfig() *Config {
+	return &Config{
+		Serialization: SerializationJson,
+		HashAlgorithm: HashAlgorithmSha256,
+		HashEncoding:  HashEncodingHex,
+		WarnOnDrift:   true,
+		commands:      newCommandCache(),
+	}
+}
+
+func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+	config := newConfig()
+	config.FailOnDrift = d.Get(FieldFailOnDrift).(bool)
+	config.HmacKey = d.Get(FieldHmacKey).(string)
+	config.Serialization = d.Get(FieldSerialization).(string)
+	config.HashAlgorithm = d.Get(FieldHashAlgorithm).(string)
+	config.IdFromHash = d.Get(FieldIdFromHash).(bool)
+	config.HashEncoding = d.Get(FieldHashEncoding).(string)
+	config.Salt = d.Get(FieldSalt).(string)
+	config.WarnOnDrift = d.Get(FieldWarnOnDrift).(bool)
+	config.RetainReal = d.Get(FieldRetainReal).(bool)
+	config.StrictHashCheck = d.Get(FieldStrictHashCheck).(bool)
+	return config, nil
+}
+
+func validateSerialization(v interface{}, k string) ([]string, []error) {
+	switch v.(string) {
+	case SerializationJson, SerializationCbor, SerializationRaw:
+		return nil, nil
+	}
+	return nil, []error{fmt.Errorf("'%s' must be one of '%s', '%s' or '%s', got '%s'",
+		k, SerializationJson, SerializationCbor, SerializationRaw, v)}
+}
+
+func validateHashEncoding(v interface{}, k string) ([]string, []error) {
+	switch v.(string) {
+	case HashEncodingHex, HashEncodingBase64url:
+		return nil, nil
+	}
+	return nil, []error{fmt.Errorf("'%s' must be either '%s' or '%s', got '%s'", k, HashEncodingHex, HashEncodingBase64url, v)}
+}
+
+func validateHashAlgorithm(v interface{}, k string) ([]string, []error) {
+	if _, ok := hashAlgorithms[v.(string)]; ok {
+		return nil, nil
+	}
+	var supported []string
+	for name := range hashAlgorithms {
+		supported = append(supported, "'"+name+"'")
+	}
+	sort.Strings(supported)
+	return nil, []error{fmt.Errorf("'%s' must be one of %s, got '%s'", k, strings.Join(supported, ", "), v)}
+}
+
+// providerConfig extracts provider configuration from the meta argument and falls back to the defaults when the
+// provider was not configured
+func providerConfig(m interface{}) *Config {
+	if config, ok := m.(*Config); ok && config != nil {
+		return config
+	}
+	return newConfig()
+}
@@ -0,0 +1,43 @@
+package stateful
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceStatefulMap() *schema.Resource {
+	return &schema.Resource{
+		Read: readMapDataSource,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldDesired: {
+				Type:     schema.TypeMap,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldCanonicalJsonValues: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			// "Outputs"
+			FieldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func readMapDataSource(d *schema.ResourceData, m interface{}) error {
+	desired := d.Get(FieldDesired)
+	if getBool(d, FieldCanonicalJsonValues) {
+		desired = transforms[TransformJson](desired)
+	}
+	hash := getSerializedHash(desired, m)
+
+	// Unlike resources the id is derived from the value so that it's stable
+	d.SetId(hash)
+	d.Set(FieldHash, hash)
+	return nil
+}
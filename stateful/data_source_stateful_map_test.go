@@ -0,0 +1,67 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateMapDataSource = `
+data "stateful_map" "object" {
+  desired               = {%s}
+  canonical_json_values = true
+}
+`
+
+func TestStatefulMapDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateMapDataSource, `foo = "{\"b\": 1, \"a\": 2}"`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("data.stateful_map.object", "hash", strPtr(getSHA256(map[string]string{"foo": `{"a":2,"b":1}`}))),
+					testResourceAttrEquals("data.stateful_map.object", "id", strPtr(getSHA256(map[string]string{"foo": `{"a":2,"b":1}`}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateMapDataSource, ""),
+				Check: resource.ComposeTestCheckFunc(
+					// sha256 of `{}`
+					testResourceAttrEquals("data.stateful_map.object", "hash", strPtr("44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a")),
+				),
+			},
+		},
+	})
+}
+
+const templateMapDataSourceHashSettings = `
+provider "stateful" {
+  hash_algorithm = "md5"
+  salt           = "pepper"
+}
+resource "stateful_map" "object" {
+  desired = { a = "1" }
+}
+data "stateful_map" "object" {
+  desired = { a = "1" }
+}
+`
+
+func TestStatefulMapDataSourceHashSettings(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateMapDataSourceHashSettings,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.stateful_map.object", "hash", "stateful_map.object", "hash"),
+					testResourceAttrDoesNotEqual("data.stateful_map.object", "hash", strPtr(getSHA256(map[string]string{"a": "1"}))),
+				),
+			},
+		},
+	})
+}
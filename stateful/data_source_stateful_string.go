@@ -0,0 +1,33 @@
+package stateful
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceStatefulString() *schema.Resource {
+	return &schema.Resource{
+		Read: readStringDataSource,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldDesired: {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			// "Outputs"
+			FieldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func readStringDataSource(d *schema.ResourceData, m interface{}) error {
+	hash := getSerializedHash(d.Get(FieldDesired), m)
+
+	// Unlike resources the id is derived from the value so that it's stable
+	d.SetId(hash)
+	d.Set(FieldHash, hash)
+	return nil
+}
@@ -0,0 +1,66 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateStringDataSource = `
+data "stateful_string" "object" {
+  desired = "%s"
+}
+`
+
+func TestStatefulStringDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateStringDataSource, "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("data.stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("data.stateful_string.object", "id", strPtr(getSHA256("foo"))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateStringDataSource, "bar"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("data.stateful_string.object", "hash", strPtr(getSHA256("bar"))),
+					testResourceAttrEquals("data.stateful_string.object", "id", strPtr(getSHA256("bar"))),
+				),
+			},
+		},
+	})
+}
+
+const templateStringDataSourceHashSettings = `
+provider "stateful" {
+  hash_algorithm = "md5"
+  salt           = "pepper"
+}
+resource "stateful_string" "object" {
+  desired = "foo"
+}
+data "stateful_string" "object" {
+  desired = "foo"
+}
+`
+
+func TestStatefulStringDataSourceHashSettings(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateStringDataSourceHashSettings,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.stateful_string.object", "hash", "stateful_string.object", "hash"),
+					testResourceAttrDoesNotEqual("data.stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,65 @@
+package stateful
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+const FieldValues = "values"
+
+const FieldDuplicates = "duplicates"
+const FieldAllUnique = "all_unique"
+
+func dataSourceStatefulUniqueness() *schema.Resource {
+	return &schema.Resource{
+		Read: readUniqueness,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldValues: {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			// "Outputs"
+			FieldDuplicates: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldAllUnique: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// getDuplicates groups values by their hashes computed as configured for the provider and returns every value that
+// occurs more than once in the order of its first occurrence
+func getDuplicates(values []interface{}, m interface{}) []interface{} {
+	counts := make(map[string]int, len(values))
+	for _, value := range values {
+		counts[getSerializedHash(value, m)]++
+	}
+
+	duplicates := []interface{}{}
+	for _, value := range values {
+		hash := getSerializedHash(value, m)
+		if counts[hash] > 1 {
+			duplicates = append(duplicates, value)
+			// Every duplicate is reported once
+			counts[hash] = 0
+		}
+	}
+	return duplicates
+}
+
+func readUniqueness(d *schema.ResourceData, m interface{}) error {
+	values := d.Get(FieldValues).([]interface{})
+	duplicates := getDuplicates(values, m)
+
+	d.SetId(getSerializedHash(values, m))
+	d.Set(FieldDuplicates, duplicates)
+	d.Set(FieldAllUnique, len(duplicates) == 0)
+	return nil
+}
@@ -0,0 +1,60 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateUniqueness = `
+data "stateful_uniqueness" "fleet" {
+  values = [%s]
+}
+`
+
+func TestStatefulUniqueness(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateUniqueness, `"a", "b", "c"`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "all_unique", strPtr("true")),
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "duplicates.#", strPtr("0")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateUniqueness, `"b", "a", "b", "c", "a", "b"`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "all_unique", strPtr("false")),
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "duplicates.#", strPtr("2")),
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "duplicates.0", strPtr("b")),
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "duplicates.1", strPtr("a")),
+				),
+			},
+		},
+	})
+}
+
+func TestStatefulUniquenessHashAlgorithm(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+provider "stateful" {
+  hash_algorithm = "md5"
+}
+` + fmt.Sprintf(templateUniqueness, `"a", "a"`),
+				Check: resource.ComposeTestCheckFunc(
+					// md5 of `["a","a"]`
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "id", strPtr("2035fe38b3026b13e9b1d070c29ae8c8")),
+					testResourceAttrEquals("data.stateful_uniqueness.fleet", "duplicates.#", strPtr("1")),
+				),
+			},
+		},
+	})
+}
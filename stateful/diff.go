@@ -0,0 +1,148 @@
+package stateful
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffEdits bounds the number of inserted and deleted lines a diff is computed for, as memory required by Myers'
+// algorithm grows quadratically with it
+const maxDiffEdits = 1000
+
+type diffLine struct {
+	text string
+	// noEOL is only set for the last line of a value lacking the trailing newline the other value has
+	noEOL bool
+}
+
+type diffEdit struct {
+	op   byte
+	line diffLine
+}
+
+// getUnifiedDiff renders a line-based unified diff between two strings using a single hunk that spans all lines, it's
+// empty when the values are equal or differ in more than maxDiffEdits lines
+func getUnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	fromLines := splitLines(from)
+	toLines := splitLines(to)
+	if from != "" && to != "" && strings.HasSuffix(from, "\n") != strings.HasSuffix(to, "\n") {
+		if strings.HasSuffix(from, "\n") {
+			toLines[len(toLines)-1].noEOL = true
+		} else {
+			fromLines[len(fromLines)-1].noEOL = true
+		}
+	}
+
+	edits, ok := getEdits(fromLines, toLines)
+	if !ok {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	fmt.Fprintf(&b, "@@ -%s +%s @@\n", getHunkRange(len(fromLines)), getHunkRange(len(toLines)))
+	for _, edit := range edits {
+		b.WriteByte(edit.op)
+		b.WriteString(edit.line.text + "\n")
+		if edit.line.noEOL {
+			b.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	return b.String()
+}
+
+// getEdits computes the shortest edit script between the lines with Myers' algorithm, it fails once the script
+// exceeds maxDiffEdits
+func getEdits(a, b []diffLine) ([]diffEdit, bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxDiffEdits {
+		limit = maxDiffEdits
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k, trace[d] keeps diagonals -d..d as of the end of step d-1
+	offset := limit + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackEdits(a, b, trace, d), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func backtrackEdits(a, b []diffLine, trace [][]int, d int) []diffEdit {
+	x, y := len(a), len(b)
+	var edits []diffEdit
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && v[d+k-1] < v[d+k+1] {
+			prevK = k + 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, diffEdit{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, diffEdit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, diffEdit{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, diffEdit{' ', a[x]})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func splitLines(s string) []diffLine {
+	if s == "" {
+		return nil
+	}
+	texts := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	lines := make([]diffLine, len(texts))
+	for i, text := range texts {
+		lines[i] = diffLine{text: text}
+	}
+	return lines
+}
+
+func getHunkRange(lines int) string {
+	if lines == 0 {
+		return "0,0"
+	}
+	return fmt.Sprintf("1,%d", lines)
+}
@@ -0,0 +1,41 @@
+package stateful
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetUnifiedDiff(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		from, to string
+		expected string
+	}{
+		{"equal", "foo\nbar\n", "foo\nbar\n", ""},
+		{"change", "foo", "bar", "--- a\n+++ b\n@@ -1,1 +1,1 @@\n-foo\n+bar\n"},
+		{"insert only", "foo\nbaz\n", "foo\nbar\nbaz\n", "--- a\n+++ b\n@@ -1,2 +1,3 @@\n foo\n+bar\n baz\n"},
+		{"delete only", "foo\nbar\nbaz\n", "foo\nbaz\n", "--- a\n+++ b\n@@ -1,3 +1,2 @@\n foo\n-bar\n baz\n"},
+		{"trailing newline added", "foo", "foo\n",
+			"--- a\n+++ b\n@@ -1,1 +1,1 @@\n-foo\n\\ No newline at end of file\n+foo\n"},
+		{"trailing newline removed", "foo\nbar\n", "foo\nbar",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n foo\n-bar\n+bar\n\\ No newline at end of file\n"},
+		{"empty from", "", "foo\n", "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+foo\n"},
+		{"empty to", "foo\nbar", "", "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-foo\n-bar\n"},
+	} {
+		if diff := getUnifiedDiff("a", "b", test.from, test.to); diff != test.expected {
+			t.Errorf("%s: diff %q does not match expected %q", test.name, diff, test.expected)
+		}
+	}
+}
+
+func TestGetUnifiedDiffTooLarge(t *testing.T) {
+	from := strings.Repeat("foo\n", maxDiffEdits)
+	to := strings.Repeat("bar\n", maxDiffEdits)
+	if diff := getUnifiedDiff("a", "b", from, to); diff != "" {
+		t.Errorf("diff of values differing in more than %d lines should be empty", maxDiffEdits)
+	}
+	// Large values are fine as long as they differ in a few lines only
+	if diff := getUnifiedDiff("a", "b", from+from, from+"bar\n"+from); !strings.Contains(diff, "\n+bar\n") {
+		t.Errorf("diff %q should contain the inserted line", diff)
+	}
+}
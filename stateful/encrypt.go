@@ -0,0 +1,86 @@
+package stateful
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// ciphertextPrefix marks encrypted values so that they are never mistaken for plaintext
+const ciphertextPrefix = "x25519:"
+
+// encryptDeterministic encrypts the plaintext for the holder of the private key matching the PEM-encoded X25519 public
+// key. The ephemeral key is derived from the plaintext so that identical plaintexts produce identical ciphertexts, the
+// result is of the form "x25519:" + base64(ephemeral public key || AES-256-GCM ciphertext).
+func encryptDeterministic(publicKeyPEM string, plaintext string) (string, error) {
+	recipient, err := parseEncryptionKey(publicKeyPEM)
+	if err != nil {
+		return "", err
+	}
+
+	ephemeral, err := ecdh.X25519().NewPrivateKey(getHMAC([]byte(plaintext), string(recipient.Bytes())))
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := getEncryptionAEAD(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return "", err
+	}
+	// Every plaintext gets its own ephemeral key and thus its own symmetric key, so a fixed nonce is never reused
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(ephemeral.PublicKey().Bytes(), nonce, []byte(plaintext), nil)
+
+	return ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// getEncryptionAEAD derives a symmetric key bound to both ephemeral and recipient public keys from the shared secret
+func getEncryptionAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write(shared)
+	h.Write(ephemeral.Bytes())
+	h.Write(recipient.Bytes())
+
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func parseEncryptionKey(publicKeyPEM string) (*ecdh.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM-encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := key.(*ecdh.PublicKey); ok && key.Curve() == ecdh.X25519() {
+		return key, nil
+	}
+	return nil, fmt.Errorf("public key must be an X25519 one, got %T", key)
+}
+
+func isCiphertext(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && strings.HasPrefix(s, ciphertextPrefix)
+}
+
+func validateEncryptionKey(v interface{}, k string) ([]string, []error) {
+	if _, err := parseEncryptionKey(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("'%s' is invalid: %s", k, err)}
+	}
+	return nil, nil
+}
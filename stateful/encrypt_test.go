@@ -0,0 +1,126 @@
+package stateful
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func generateEncryptionKey(t *testing.T) (*ecdh.PrivateKey, string) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(private.PublicKey())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return private, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}))
+}
+
+func decrypt(private *ecdh.PrivateKey, ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, ciphertextPrefix))
+	if err != nil {
+		return "", err
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[:32])
+	if err != nil {
+		return "", err
+	}
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return "", err
+	}
+	aead, err := getEncryptionAEAD(shared, ephemeral, private.PublicKey())
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), sealed[32:], nil)
+	return string(plaintext), err
+}
+
+func TestEncryptDeterministic(t *testing.T) {
+	private, public := generateEncryptionKey(t)
+
+	foo, err := encryptDeterministic(public, "foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if again, _ := encryptDeterministic(public, "foo"); again != foo {
+		t.Errorf("ciphertexts of the same plaintext differ: '%s' and '%s'", foo, again)
+	}
+	if bar, _ := encryptDeterministic(public, "bar"); bar == foo {
+		t.Errorf("ciphertexts of different plaintexts are the same: '%s'", foo)
+	}
+
+	plaintext, err := decrypt(private, foo)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if plaintext != "foo" {
+		t.Errorf("decrypted plaintext '%s' does not match expected 'foo'", plaintext)
+	}
+}
+
+const templateEncryptPublicKey = `
+resource "stateful_string" "object" {
+  desired            = "%s"
+  nonce              = %d
+  encrypt_public_key = <<EOT
+%sEOT
+}
+`
+
+func TestStatefulStringEncryptPublicKey(t *testing.T) {
+	private, public := generateEncryptionKey(t)
+
+	testEncrypted := func(expected string) resource.TestCheckFunc {
+		return func(state *terraform.State) error {
+			for _, attr := range []string{"desired", "diffbase"} {
+				value := getResourceAttr(state, "stateful_string.object", attr)
+				if plaintext, err := decrypt(private, value); err != nil || plaintext != expected {
+					return fmt.Errorf("attribute '%s' value '%s' is not the encrypted '%s': %v", attr, value, expected, err)
+				}
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateEncryptPublicKey, "foo", 0, public),
+				Check: resource.ComposeTestCheckFunc(
+					testEncrypted("foo"),
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+				),
+			},
+			{
+				// plaintext is not available upon apply as desired value is unchanged
+				Config: fmt.Sprintf(templateEncryptPublicKey, "foo", 1, public),
+				Check: resource.ComposeTestCheckFunc(
+					testEncrypted("foo"),
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(map[string]interface{}{"desired": "foo", "nonce": 1}))),
+					testResourceAttrEquals("stateful_string.object", "hash_changed", strPtr("true")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateEncryptPublicKey, "bar", 1, public),
+				Check: resource.ComposeTestCheckFunc(
+					testEncrypted("bar"),
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(map[string]interface{}{"desired": "bar", "nonce": 1}))),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,538 @@
+package stateful
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/agext/levenshtein"
+	"github.com/hashicorp/terraform/helper/schema"
+	"golang.org/x/text/unicode/norm"
+	"io"
+	"math"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const KeyModeExact = "exact"
+const KeyModeNumeric = "numeric"
+const KeyModeCaseInsensitive = "ci"
+const KeyModeTrim = "trim"
+
+var keyModes = map[string]func(string) string{
+	KeyModeExact:           func(s string) string { return s },
+	KeyModeNumeric:         canonicalizeNumber,
+	KeyModeCaseInsensitive: strings.ToLower,
+	KeyModeTrim:            strings.TrimSpace,
+}
+
+const TransformOmitEmpty = "omit_empty"
+const TransformQuerystring = "querystring"
+const TransformToml = "toml"
+const TransformValuesMultiset = "values_multiset"
+const TransformHeaders = "headers"
+const TransformJson = "json"
+const TransformTrim = "trim"
+const TransformLower = "lower"
+const TransformUpper = "upper"
+const TransformNfc = "nfc"
+const TransformWhitespace = "whitespace"
+
+var transforms = map[string]func(interface{}) interface{}{
+	TransformOmitEmpty:      omitEmptyValues,
+	TransformQuerystring:    canonicalizeQuery,
+	TransformToml:           canonicalizeToml,
+	TransformValuesMultiset: getSortedValues,
+	TransformHeaders:        canonicalizeHeaders,
+	TransformJson:           mapStrings(canonicalizeJson),
+	TransformTrim:           mapStrings(strings.TrimSpace),
+	TransformLower:          mapStrings(strings.ToLower),
+	TransformUpper:          mapStrings(strings.ToUpper),
+	TransformNfc:            mapStrings(norm.NFC.String),
+	TransformWhitespace:     mapStrings(normalizeWhitespace),
+}
+
+// resourceGetter is implemented by both schema.ResourceData and schema.ResourceDiff so that normalization logic can be
+// shared between CRUD operations and CustomizeDiff
+type resourceGetter interface {
+	Id() string
+	Get(key string) interface{}
+	GetOk(key string) (interface{}, bool)
+	GetChange(key string) (interface{}, interface{})
+}
+
+// getBool returns the value of a boolean option or false when the option is not defined for the resource
+func getBool(d resourceGetter, key string) bool {
+	value, _ := d.Get(key).(bool)
+	return value
+}
+
+// normalizeValue applies normalization transforms configured for the resource in order so that values are compared
+// and hashed in their canonical form
+func normalizeValue(d resourceGetter, value interface{}) interface{} {
+	set, isSet := value.(*schema.Set)
+	if isSet {
+		value = set.List()
+	}
+	if ignored, _ := d.Get(FieldIgnoreKeys).([]interface{}); len(ignored) > 0 {
+		// Ignored keys are dropped up front as transforms may discard keys, e.g. values_multiset
+		value = omitKeys(value, ignored)
+	}
+	if pattern, _ := d.Get(FieldIgnorePattern).(string); pattern != "" {
+		// Invalid patterns fail the plan, see diffString
+		if ignored, err := regexp.Compile(pattern); err == nil {
+			value = mapStrings(func(s string) string { return ignored.ReplaceAllString(s, "") })(value)
+		}
+	}
+	if getBool(d, FieldCanonicalize) {
+		// JSON documents are canonicalized up front so that transforms operate on the canonical form
+		value = transforms[TransformJson](value)
+	}
+	for _, name := range getTransforms(d) {
+		value = transforms[name](value)
+	}
+	if isSet {
+		// Sets are unordered, also values may become duplicate only after they are transformed
+		value = getCanonicalSet(value.([]interface{}))
+	}
+	return value
+}
+
+// getCanonicalSet sorts set elements by their JSON representation and drops duplicates so that sets with the same
+// members are equal regardless of the order they were listed in
+func getCanonicalSet(elements []interface{}) []interface{} {
+	serialized := make(map[string]interface{}, len(elements))
+	for _, element := range elements {
+		key, _ := json.Marshal(element)
+		serialized[string(key)] = element
+	}
+	keys := make([]string, 0, len(serialized))
+	for key := range serialized {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]interface{}, len(keys))
+	for i, key := range keys {
+		result[i] = serialized[key]
+	}
+	return result
+}
+
+// getTransforms returns the explicitly configured transforms or, when there are none, the ones implied by individual
+// normalization options in their historical order
+func getTransforms(d resourceGetter) []string {
+	if configured, ok := d.GetOk(FieldTransforms); ok {
+		names := make([]string, len(configured.([]interface{})))
+		for i, name := range configured.([]interface{}) {
+			names[i] = name.(string)
+		}
+		return names
+	}
+
+	var names []string
+	for _, option := range []struct {
+		field     string
+		transform string
+	}{
+		{FieldNormalizeWhitespace, TransformWhitespace},
+		{FieldOmitEmpty, TransformOmitEmpty},
+		{FieldQuerystringValue, TransformQuerystring},
+		{FieldTomlValue, TransformToml},
+		{FieldCanonicalJsonValues, TransformJson},
+		{FieldValuesMultiset, TransformValuesMultiset},
+		{FieldHeadersValue, TransformHeaders},
+		{FieldCaseInsensitive, TransformLower},
+	} {
+		if getBool(d, option.field) {
+			names = append(names, option.transform)
+		}
+	}
+	return names
+}
+
+// mapStrings lifts a string function to apply to strings as well as to every value of maps and lists
+func mapStrings(f func(string) string) func(interface{}) interface{} {
+	return func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			return f(v)
+		case map[string]interface{}:
+			result := make(map[string]interface{}, len(v))
+			for k, e := range v {
+				if s, ok := e.(string); ok {
+					e = f(s)
+				}
+				result[k] = e
+			}
+			return result
+		case []string:
+			result := make([]string, len(v))
+			for i, e := range v {
+				result[i] = f(e)
+			}
+			return result
+		case []interface{}:
+			result := make([]interface{}, len(v))
+			for i, e := range v {
+				if s, ok := e.(string); ok {
+					e = f(s)
+				}
+				result[i] = e
+			}
+			return result
+		}
+		return value
+	}
+}
+
+// canonicalizeJson re-encodes a JSON document with keys sorted and insignificant whitespace dropped, values that
+// cannot be parsed as JSON are returned untouched. Numbers are kept verbatim rather than decoded as float64 so that
+// large integers don't lose precision, e.g. 10000000000000001.
+func canonicalizeJson(s string) string {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return s
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		// Trailing data
+		return s
+	}
+	serialized, _ := json.Marshal(canonicalizeJsonNumbers(document))
+	return string(serialized)
+}
+
+// canonicalizeJsonNumbers keeps integers verbatim while the rest of numbers are converted to float64 so that e.g. 1.0
+// and 1e0 are rendered as 1
+func canonicalizeJsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			return v
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, element := range v {
+			v[key] = canonicalizeJsonNumbers(element)
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = canonicalizeJsonNumbers(element)
+		}
+	}
+	return value
+}
+
+// normalizeWhitespace normalizes line endings to LF and drops trailing whitespace of every line, values consisting of
+// whitespace only become empty
+func normalizeWhitespace(s string) string {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	if normalized := strings.Join(lines, "\n"); strings.TrimSpace(normalized) != "" {
+		return normalized
+	}
+	return ""
+}
+
+func validateTransform(v interface{}, k string) ([]string, []error) {
+	if _, ok := transforms[v.(string)]; !ok {
+		var names []string
+		for name := range transforms {
+			names = append(names, "'"+name+"'")
+		}
+		sort.Strings(names)
+		return nil, []error{fmt.Errorf("%s: unsupported transform '%s', must be one of %s", k, v, strings.Join(names, ", "))}
+	}
+	return nil, nil
+}
+
+// omitEmptyValues drops map entries with empty or null values as those are considered semantically absent
+func omitEmptyValues(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if v == nil || v == "" {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// omitKeys drops the given keys from a map, keys that are not present are ignored
+func omitKeys(value interface{}, keys []interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	for _, key := range keys {
+		delete(result, key.(string))
+	}
+	return result
+}
+
+// getSortedValues drops map keys and sorts the values so that only their multiset matters
+func getSortedValues(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	values := make([]string, 0, len(m))
+	for _, v := range m {
+		values = append(values, v.(string))
+	}
+	sort.Strings(values)
+	return values
+}
+
+// canonicalizeQuery re-encodes a URL query string with parameters sorted by name, values that cannot be parsed as a
+// query string are returned untouched
+func canonicalizeQuery(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	query, err := url.ParseQuery(s)
+	if err != nil {
+		return value
+	}
+	return query.Encode()
+}
+
+// canonicalizeHeaders re-encodes an RFC 822 style header block with folded lines unfolded, names canonicalized and
+// headers sorted by name (values of repeated headers keep their order), values that cannot be parsed as a header
+// block are returned untouched
+func canonicalizeHeaders(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	reader := bufio.NewReader(strings.NewReader(strings.TrimRight(s, "\r\n") + "\n\n"))
+	headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil || reader.Buffered() > 0 {
+		// Anything following the header block, e.g. a message body, is not supported
+		return value
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, v := range headers[name] {
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+	return b.String()
+}
+
+// getComparableValue projects an already normalized value into the form used only for comparison against real state,
+// e.g. applying per-key comparison modes
+func getComparableValue(d resourceGetter, value interface{}) interface{} {
+	modes, _ := d.Get(FieldKeyModes).(map[string]interface{})
+	m, ok := value.(map[string]interface{})
+	if !ok || len(modes) == 0 {
+		return value
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		mode, hasMode := modes[k]
+		if s, isString := v.(string); hasMode && isString {
+			v = keyModes[mode.(string)](s)
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// isMatching checks whether already normalized desired and real values are equal in their comparable form or, for
+// strings, similar enough when a similarity threshold is set, or, for numbers, close enough when a tolerance is set.
+// In merge mode a real map matches when it's a superset of the desired one.
+func isMatching(d resourceGetter, desired, real interface{}) bool {
+	desired, real = getComparableValue(d, desired), getComparableValue(d, real)
+	if reflect.DeepEqual(desired, real) {
+		return true
+	}
+
+	desiredMap, desiredIsMap := desired.(map[string]interface{})
+	realMap, realIsMap := real.(map[string]interface{})
+	if getBool(d, FieldMerge) && desiredIsMap && realIsMap {
+		return isSubset(desiredMap, realMap)
+	}
+
+	desiredNumber, desiredIsNumber := desired.(float64)
+	realNumber, realIsNumber := real.(float64)
+	if desiredIsNumber && realIsNumber {
+		// Ordering comparisons hold on their own, otherwise numbers may still match within the tolerance
+		switch d.Get(FieldCompare) {
+		case CompareGte:
+			if realNumber >= desiredNumber {
+				return true
+			}
+		case CompareLte:
+			if realNumber <= desiredNumber {
+				return true
+			}
+		}
+		if tolerance, ok := d.GetOk(FieldTolerance); ok {
+			return math.Abs(desiredNumber-realNumber) <= tolerance.(float64)
+		}
+	}
+
+	threshold, ok := d.GetOk(FieldSimilarityThreshold)
+	desiredString, desiredIsString := desired.(string)
+	realString, realIsString := real.(string)
+	if !ok || !desiredIsString || !realIsString {
+		return false
+	}
+	return levenshtein.Similarity(desiredString, realString, nil) >= threshold.(float64)
+}
+
+// isSubset checks whether every entry of the map is present in the other one, keys with empty values included
+func isSubset(m, other map[string]interface{}) bool {
+	for k, v := range m {
+		if o, exists := other[k]; !exists || !reflect.DeepEqual(v, o) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRealMatching normalizes the real value and checks whether it matches already normalized desired one or any of
+// candidates accepted in its place
+func isRealMatching(d resourceGetter, desired, real interface{}) bool {
+	if isRealMatchingValue(d, desired, real) {
+		return true
+	}
+	candidates, _ := d.Get(FieldCandidates).([]interface{})
+	for _, candidate := range candidates {
+		if isRealMatchingValue(d, normalizeValue(d, candidate), real) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRealMatchingValue normalizes the real value and checks whether it matches the already normalized expected one.
+// When match_any_real is enabled, a real value that is a JSON list of strings matches when any of its elements does.
+func isRealMatchingValue(d resourceGetter, expected, real interface{}) bool {
+	if s, ok := real.(string); ok && getBool(d, FieldMatchAnyReal) {
+		var elements []string
+		if err := json.Unmarshal([]byte(s), &elements); err == nil {
+			for _, element := range elements {
+				if isMatching(d, expected, normalizeValue(d, element)) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return isMatching(d, expected, normalizeValue(d, real))
+}
+
+// isZeroValue checks whether the value is the zero value of its type, collections are zero when they are empty
+func isZeroValue(value interface{}) bool {
+	if set, ok := value.(*schema.Set); ok {
+		return set.Len() == 0
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// canonicalizeNumber formats a numeric string in its shortest representation so that "1", "1.0" and "1e0" are equal,
+// values that cannot be parsed as numbers are returned untouched
+func canonicalizeNumber(s string) string {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return s
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// sortNumerically sorts numeric strings by their values so that "2" precedes "10", the rest follow in lexical order
+func sortNumerically(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(keys[i], 64)
+		b, errB := strconv.ParseFloat(keys[j], 64)
+		switch {
+		case errA == nil && errB == nil && a != b:
+			return a < b
+		case (errA == nil) != (errB == nil):
+			return errA == nil
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+func validateKeyModes(v interface{}, k string) ([]string, []error) {
+	var errs []error
+	for key, mode := range v.(map[string]interface{}) {
+		if _, ok := keyModes[mode.(string)]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unsupported mode '%s' for key '%s', must be one of '%s', '%s', '%s' or '%s'",
+				k, mode, key, KeyModeExact, KeyModeNumeric, KeyModeCaseInsensitive, KeyModeTrim))
+		}
+	}
+	return nil, errs
+}
+
+// getOrderedEntries turns a map into a list of [key, value] pairs following the given key order so that the order is
+// captured by the serialized form. Keys that are not listed follow the listed ones in lexical or, if requested, numeric
+// order.
+func getOrderedEntries(value interface{}, order []interface{}, numeric bool) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+
+	var keys []string
+	listed := make(map[string]bool, len(order))
+	for _, key := range order {
+		if _, exists := m[key.(string)]; exists && !listed[key.(string)] {
+			keys = append(keys, key.(string))
+			listed[key.(string)] = true
+		}
+	}
+	var rest []string
+	for key := range m {
+		if !listed[key] {
+			rest = append(rest, key)
+		}
+	}
+	if numeric {
+		sortNumerically(rest)
+	} else {
+		sort.Strings(rest)
+	}
+
+	entries := make([]interface{}, 0, len(m))
+	for _, key := range append(keys, rest...) {
+		entries = append(entries, []interface{}{key, m[key]})
+	}
+	return entries
+}
@@ -7,9 +7,83 @@ import (
 
 func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
+		Schema: map[string]*schema.Schema{
+			FieldFailOnDrift: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldWarnOnDrift: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+			FieldRetainReal: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldStrictHashCheck: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldHmacKey: {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			FieldSerialization: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      SerializationJson,
+				ValidateFunc: validateSerialization,
+			},
+			FieldHashAlgorithm: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      HashAlgorithmSha256,
+				ValidateFunc: validateHashAlgorithm,
+			},
+			FieldHashEncoding: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      HashEncodingHex,
+				ValidateFunc: validateHashEncoding,
+			},
+			FieldSalt: {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			FieldIdFromHash: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
 		ResourcesMap: map[string]*schema.Resource{
-			"stateful_string": resourceStatefulString(),
-			"stateful_map":    resourceStatefulMap(),
+			"stateful_string":     resourceStatefulString(),
+			"stateful_map":        resourceStatefulMap(),
+			"stateful_list":       resourceStatefulList(),
+			"stateful_set":        resourceStatefulSet(),
+			"stateful_number":     resourceStatefulNumber(),
+			"stateful_bool":       resourceStatefulBool(),
+			"stateful_json":       resourceStatefulJson(),
+			"stateful_typed_map":  resourceStatefulTypedMap(),
+			"stateful_file":       resourceStatefulFile(),
+			"stateful_timestamp":  resourceStatefulTimestamp(),
+			"stateful_exec":       resourceStatefulExec(),
+			"stateful_string_set": resourceStatefulStringSet(),
+			"stateful_summary":    resourceStatefulSummary(),
+			"stateful_freshness":  resourceStatefulFreshness(),
+			"stateful_composite":  resourceStatefulComposite(),
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"stateful_string":     dataSourceStatefulString(),
+			"stateful_map":        dataSourceStatefulMap(),
+			"stateful_uniqueness": dataSourceStatefulUniqueness(),
 		},
+		ConfigureFunc: providerConfigure,
 	}
 }
@@ -1,8 +1,13 @@
 package stateful
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"regexp"
 	"testing"
 
+	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/terraform-providers/terraform-provider-null/null"
@@ -28,3 +33,321 @@ func TestProvider(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+const templateFailOnDrift = `
+provider "stateful" {
+  fail_on_drift = true
+}
+resource "stateful_string" "object" {
+  desired = "%s"
+  real    = "%s"
+}
+`
+
+func TestProviderFailOnDrift(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateFailOnDrift, "foo", "foo"), // in sync
+			},
+			{
+				Config:      fmt.Sprintf(templateFailOnDrift, "foo", "bar"), // drift
+				ExpectError: regexp.MustCompile("diverges from the desired one"),
+			},
+		},
+	})
+}
+
+const templateHashAlgorithm = `
+provider "stateful" {
+  hash_algorithm = "%s"
+}
+resource "stateful_string" "object" {
+  desired = "foo"
+}
+`
+
+func TestProviderHashAlgorithm(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateHashAlgorithm, "crc32"),
+				ExpectError: regexp.MustCompile("'hash_algorithm' must be one of 'md5', 'sha1', 'sha256', 'sha512'"),
+			},
+			{
+				Config: fmt.Sprintf(templateHashAlgorithm, "md5"),
+				Check: resource.ComposeTestCheckFunc(
+					// md5 of `"foo"`
+					resource.TestCheckResourceAttr("stateful_string.object", "hash", "0dba520e335c06ba9240a978e9455878"),
+					resource.TestCheckResourceAttr("stateful_string.object", "effective_config.algorithm", "md5"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateHashAlgorithm, "sha256"),
+				Check: resource.TestCheckResourceAttr("stateful_string.object", "hash",
+					"b2213295d564916f89a6a42455567c87c3f480fcd7a1c15e220f17d7169a790b"),
+			},
+		},
+	})
+}
+
+const templateIdFromHash = `
+provider "stateful" {
+  id_from_hash = true
+}
+resource "stateful_string" "object" {
+  desired = "%s"
+}
+`
+
+func TestProviderIdFromHash(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateIdFromHash, "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("stateful_string.object", "id", getSHA256("foo")),
+					resource.TestCheckResourceAttr("stateful_string.object", "id_source", "hash"),
+					resource.TestCheckResourceAttr("stateful_string.object", "lock_token", getLockToken(getSHA256("foo"), getSHA256("foo"))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateIdFromHash, "bar"), // forces a new resource
+				Check:  resource.TestCheckResourceAttr("stateful_string.object", "id", getSHA256("bar")),
+			},
+		},
+	})
+}
+
+const templateIdFromHashSettings = `
+provider "stateful" {
+  id_from_hash   = true
+  hash_algorithm = "%s"
+}
+resource "stateful_string" "object" {
+  desired = "foo"
+}
+`
+
+func TestProviderIdFromHashSettings(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateIdFromHashSettings, "sha256"),
+				Check:  resource.TestCheckResourceAttr("stateful_string.object", "id", getSHA256("foo")),
+			},
+			{
+				Config: fmt.Sprintf(templateIdFromHashSettings, "md5"), // the hash changes so the id follows it
+				Check: resource.ComposeTestCheckFunc(
+					// md5 of `"foo"`
+					resource.TestCheckResourceAttr("stateful_string.object", "id", "0dba520e335c06ba9240a978e9455878"),
+					resource.TestCheckResourceAttr("stateful_string.object", "hash", "0dba520e335c06ba9240a978e9455878"),
+					resource.TestCheckResourceAttr("stateful_string.object", "generation", "1"),
+				),
+			},
+		},
+	})
+}
+
+const templateHashEncoding = `
+provider "stateful" {
+  hash_encoding = "%s"
+}
+resource "stateful_string" "object" {
+  desired       = "foo"
+  truncate_hash = 10
+}
+`
+
+func TestProviderHashEncoding(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateHashEncoding, "base32"),
+				ExpectError: regexp.MustCompile("'hash_encoding' must be either 'hex' or 'base64url', got 'base32'"),
+			},
+			{
+				Config: fmt.Sprintf(templateHashEncoding, "base64url"),
+				Check: resource.ComposeTestCheckFunc(
+					// base64url of sha256 of `"foo"`, truncated to 10 characters
+					resource.TestCheckResourceAttr("stateful_string.object", "hash", "siEyldVkkW"),
+					resource.TestCheckResourceAttr("stateful_string.object", "effective_config.encoding", "base64url"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateHashEncoding, "hex"),
+				Check:  resource.TestCheckResourceAttr("stateful_string.object", "hash", getSHA256("foo")[:10]),
+			},
+		},
+	})
+}
+
+const templateSalt = `
+provider "stateful" {
+  salt = "%s"
+}
+resource "stateful_string" "object" {
+  desired = "true"
+}
+`
+
+func TestProviderSalt(t *testing.T) {
+	var hash string
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateSalt, "pepper"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("stateful_string.object", "hash", getDigest([]byte(`pepper"true"`))),
+					resource.TestCheckResourceAttr("stateful_string.object", "effective_config.salt_present", "true"),
+					func(state *terraform.State) error {
+						hash = getResourceAttr(state, "stateful_string.object", "hash")
+						return nil
+					},
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateSalt, "paprika"), // flipping the salt changes the hash
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrDoesNotEqual("stateful_string.object", "hash", &hash),
+					resource.TestCheckResourceAttr("stateful_string.object", "hash", getDigest([]byte(`paprika"true"`))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateSalt, ""),
+				Check:  resource.TestCheckResourceAttr("stateful_string.object", "hash", getSHA256("true")),
+			},
+		},
+	})
+}
+
+const templateResourceHashSettings = `
+provider "stateful" {
+  hash_algorithm = "md5"
+}
+resource "stateful_string" "default" {
+  desired = "foo"
+}
+resource "stateful_string" "override" {
+  desired = "foo"
+  %s
+}
+`
+
+func TestProviderResourceHashSettings(t *testing.T) {
+	sum := sha256.Sum256([]byte(`pepper"foo"`))
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateResourceHashSettings, `digest_algorithm = "crc32"`),
+				ExpectError: regexp.MustCompile("'digest_algorithm' must be one of 'md5', 'sha1', 'sha256', 'sha512'"),
+			},
+			{
+				Config: fmt.Sprintf(templateResourceHashSettings, `digest_algorithm = "sha256"
+  salt             = "pepper"`),
+				Check: resource.ComposeTestCheckFunc(
+					// md5 of `"foo"`
+					resource.TestCheckResourceAttr("stateful_string.default", "hash", "0dba520e335c06ba9240a978e9455878"),
+					resource.TestCheckResourceAttr("stateful_string.override", "hash", getDigest([]byte(`pepper"foo"`))),
+					resource.TestCheckResourceAttr("stateful_string.override", "effective_config.algorithm", "sha256"),
+					resource.TestCheckResourceAttr("stateful_string.override", "effective_config.salt_present", "true"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateResourceHashSettings, `digest_algorithm = "sha256"
+  digest_encoding  = "base64url"
+  salt             = "pepper"`),
+				Check: resource.TestCheckResourceAttr("stateful_string.override", "hash",
+					base64.RawURLEncoding.EncodeToString(sum[:])),
+			},
+			{
+				Config: fmt.Sprintf(templateResourceHashSettings, ""), // falls back to provider settings
+				Check: resource.TestCheckResourceAttr("stateful_string.override", "hash",
+					"0dba520e335c06ba9240a978e9455878"),
+			},
+		},
+	})
+}
+
+const templateRetainReal = `
+provider "stateful" {
+  retain_real = %t
+}
+resource "stateful_string" "object" {
+  desired = "foo"
+  real    = "foo"
+}
+`
+
+func TestProviderRetainReal(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateRetainReal, false),
+				Check:  testResourceAttrEquals("stateful_string.object", "real", strPtr("")),
+			},
+			{
+				Config: fmt.Sprintf(templateRetainReal, true),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "real", strPtr("foo")),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					// the hash is still derived from desired only
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+				),
+			},
+		},
+	})
+}
+
+const templateHmacKey = `
+provider "stateful" {
+  hmac_key = "%s"
+}
+resource "stateful_string" "object" {
+  desired = "true"
+}
+`
+
+func TestProviderHmacKey(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateHmacKey, "secret"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("stateful_string.object", "hash",
+						fmt.Sprintf("%x", getHMAC([]byte(`"true"`), "secret"))),
+					resource.TestCheckResourceAttr("stateful_string.object", "effective_config.hmac_key_present", "true"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateHmacKey, "rotated"), // rotating the key changes the hash
+				Check: resource.TestCheckResourceAttr("stateful_string.object", "hash",
+					fmt.Sprintf("%x", getHMAC([]byte(`"true"`), "rotated"))),
+			},
+			{
+				Config: fmt.Sprintf(templateHmacKey, ""),
+				Check:  resource.TestCheckResourceAttr("stateful_string.object", "hash", getSHA256("true")),
+			},
+		},
+	})
+}
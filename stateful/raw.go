@@ -0,0 +1,39 @@
+package stateful
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// getRaw encodes strings as their UTF-8 bytes and maps as 'key=value\n' lines sorted by key so that hashes of plain
+// values match the ones computed with command line tools, e.g. `echo -n value | sha256sum`. Anything else, including
+// map values that aren't strings, is encoded as JSON.
+func getRaw(o interface{}) []byte {
+	switch v := o.(type) {
+	case string:
+		return []byte(v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var b bytes.Buffer
+		for _, key := range keys {
+			b.WriteString(key)
+			b.WriteByte('=')
+			if s, ok := v[key].(string); ok {
+				b.WriteString(s)
+			} else {
+				serialized, _ := json.Marshal(v[key])
+				b.Write(serialized)
+			}
+			b.WriteByte('\n')
+		}
+		return b.Bytes()
+	}
+	serialized, _ := json.Marshal(o)
+	return serialized
+}
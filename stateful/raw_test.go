@@ -0,0 +1,58 @@
+package stateful
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestRaw(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"foo", "foo"},
+		{"", ""},
+		{map[string]interface{}{"b": "2", "a": "1"}, "a=1\nb=2\n"},
+		{map[string]interface{}{"desired": "foo", "nonce": 1}, "desired=foo\nnonce=1\n"},
+		{[]interface{}{"a", "b"}, `["a","b"]`},
+		{1.5, "1.5"},
+	}
+	for _, c := range cases {
+		if actual := string(getRaw(c.value)); actual != c.expected {
+			t.Errorf("getRaw(%#v) = %q, expected %q", c.value, actual, c.expected)
+		}
+	}
+}
+
+const templateSerializationRaw = `
+provider "stateful" {
+  serialization = "raw"
+}
+resource "stateful_string" "string" {
+  desired = "foo"
+}
+resource "stateful_map" "map" {
+  desired = {
+    b = "2"
+    a = "1"
+  }
+}
+`
+
+func TestProviderSerializationRaw(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateSerializationRaw,
+				Check: resource.ComposeTestCheckFunc(
+					// echo -n foo | sha256sum
+					testResourceAttrEquals("stateful_string.string", "hash", strPtr("2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")),
+					testResourceAttrEquals("stateful_map.map", "hash", strPtr(getDigest([]byte("a=1\nb=2\n")))),
+				),
+			},
+		},
+	})
+}
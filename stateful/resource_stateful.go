@@ -1,29 +1,430 @@
 package stateful
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/satori/go.uuid"
+	"hash"
+	"log"
+	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const FieldDesired = "desired"
 const FieldReal = "real"
 
+const FieldNonce = "nonce"
+const FieldObserveCommand = "observe_command"
+const FieldPublishCommand = "publish_command"
+
+const FieldSources = "sources"
+
+const FieldSignToken = "sign_token"
+const FieldGroupSize = "group_size"
+
+// Hash length must not be prefixed by the hash, e.g. hash_length, as ResourceDiff.SetNew drops diffs of such fields
+const FieldTruncateHash = "truncate_hash"
+const FieldAllowedHashes = "allowed_hashes"
+const FieldRequireApproved = "require_approved"
+const FieldTtl = "ttl"
+const FieldRotationPeriod = "rotation_period"
+const FieldRequireReal = "require_real"
+const FieldDriftGracePeriod = "drift_grace_period"
+const FieldDebugLog = "debug_log"
+const FieldEphemeral = "ephemeral"
+const FieldIdInput = "id_input"
+
+const FieldTransforms = "transforms"
+const FieldOmitEmpty = "omit_empty"
+const FieldQuerystringValue = "querystring_value"
+const FieldTomlValue = "toml_value"
+const FieldHeadersValue = "headers_value"
+const FieldMatchAnyReal = "match_any_real"
+const FieldEncryptPublicKey = "encrypt_public_key"
+const FieldSimilarityThreshold = "similarity_threshold"
+const FieldKeyModes = "key_modes"
+const FieldOrderedKeys = "ordered_keys"
+const FieldNumericKeys = "numeric_keys"
+const FieldValuesMultiset = "values_multiset"
+const FieldCanonicalJsonValues = "canonical_json_values"
+const FieldMerge = "merge"
+const FieldIgnoreKeys = "ignore_keys"
+const FieldTolerance = "tolerance"
+const FieldCanonicalize = "canonicalize"
+const FieldIgnorePattern = "ignore_pattern"
+const FieldCdcFingerprint = "cdc_fingerprint"
+const FieldCaseInsensitive = "case_insensitive"
+const FieldNormalizeWhitespace = "normalize_whitespace"
+
 const FieldHash = "hash"
+const FieldToken = "token"
+const FieldHashGrouped = "hash_grouped"
+const FieldHashUrlencoded = "hash_urlencoded"
+const FieldHashMd5 = "hash_md5"
+const FieldHashSha1 = "hash_sha1"
+const FieldHashSha256 = "hash_sha256"
+const FieldLockToken = "lock_token"
+const FieldHashChanged = "hash_changed"
+const FieldApproved = "approved"
+const FieldPerSourceDrift = "per_source_drift"
+const FieldAnyDrift = "any_drift"
+const FieldIdSource = "id_source"
+const FieldDrifted = "drifted"
+const FieldDriftPending = "drift_pending"
+const FieldDriftFirstObserved = "drift_first_observed"
+const FieldDiff = "diff"
+const FieldChangeReport = "change_report"
+const FieldChunkHashes = "chunk_hashes"
+const FieldChunkCount = "chunk_count"
+const FieldEffectiveConfig = "effective_config"
+const FieldLastChanged = "last_changed"
+const FieldDiffbase = "diffbase"
+const FieldAge = "age"
+
+// Rotation counter must not be a prefix of other fields as ResourceDiff.SetNew drops their diffs
+const FieldRotationCount = "rotation_count"
+const FieldRotationPending = "rotation_pending"
+const FieldNextRotation = "next_rotation"
+const FieldRotationDue = "rotation_due"
+const FieldApplyNonce = "apply_nonce"
+const FieldIsEmpty = "is_empty"
+const FieldRealHash = "real_hash"
+const FieldIsRealSet = "is_real_set"
+const FieldGeneration = "generation"
+const FieldLastUpdated = "last_updated"
+const FieldAcceptReal = "accept_real"
+const FieldHashSource = "hash_source"
+const FieldForceRecreate = "force_recreate"
+const FieldKeepers = "keepers"
+const FieldDigestAlgorithm = "digest_algorithm"
+const FieldDigestEncoding = "digest_encoding"
+
+// "count" is reserved by Terraform
+const FieldLength = "length"
+
+// Unlike hash_raw these are not prefixed with "hash" as planning the hash would drop their diffs
+const FieldPrefix = "prefix"
+const FieldSuffix = "suffix"
+const FieldHashRaw = "hash_raw"
+const FieldNormalizedValue = "normalized_value"
+const FieldSize = "size"
+const FieldCandidates = "candidates"
+const FieldOldHash = "old_hash"
+const FieldCompare = "compare"
+const FieldStableId = "stable_id"
+const FieldValuePatterns = "value_patterns"
+
+const KeyStatusMissing = "missing"
+const KeyStatusExtra = "extra"
+const KeyStatusChanged = "changed"
+
+const IdSourceRandomV4 = "random-v4"
+const IdSourceInput = "input"
+const IdSourceHash = "hash"
+
+const HashSourceDesired = "desired"
+const HashSourceReal = "real"
+
+const CompareEq = "eq"
+const CompareGte = "gte"
+const CompareLte = "lte"
 
 func resourceStatefulString() *schema.Resource {
-	return resourceFactory(schema.TypeString)
+	resource := resourceFactory(schema.TypeString)
+
+	// "Inputs"
+	resource.Schema[FieldSources] = &schema.Schema{
+		Type:     schema.TypeMap,
+		Optional: true,
+		Elem:     &schema.Schema{Type: schema.TypeString},
+	}
+	resource.Schema[FieldQuerystringValue] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldTomlValue] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldHeadersValue] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldMatchAnyReal] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldCandidates] = &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem:     &schema.Schema{Type: schema.TypeString},
+	}
+	resource.Schema[FieldEncryptPublicKey] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validateEncryptionKey,
+		// Hash of an ephemeral resource cannot be planned while plaintext is only available upon plan
+		ConflictsWith: []string{FieldEphemeral},
+	}
+	resource.Schema[FieldSimilarityThreshold] = &schema.Schema{
+		Type:         schema.TypeFloat,
+		Optional:     true,
+		ValidateFunc: validateFraction,
+	}
+	resource.Schema[FieldCdcFingerprint] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldCaseInsensitive] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldNormalizeWhitespace] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldIgnorePattern] = &schema.Schema{
+		Type:     schema.TypeString,
+		Optional: true,
+	}
+	// "Outputs"
+	resource.Schema[FieldPerSourceDrift] = &schema.Schema{
+		Type:     schema.TypeMap,
+		Computed: true,
+		Elem:     &schema.Schema{Type: schema.TypeBool},
+	}
+	resource.Schema[FieldAnyDrift] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Computed: true,
+	}
+	resource.Schema[FieldDiff] = &schema.Schema{
+		Type:     schema.TypeString,
+		Computed: true,
+	}
+	resource.Schema[FieldChunkHashes] = &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem:     &schema.Schema{Type: schema.TypeString},
+	}
+	resource.Schema[FieldChunkCount] = &schema.Schema{
+		Type:     schema.TypeInt,
+		Computed: true,
+	}
+
+	// Encrypted desired value is stored in place of the plaintext, see encryptDesired
+	resource.Schema[FieldDesired].DiffSuppressFunc = suppressEncryptedDiff
+
+	// Resource-specific diff logic has to run before the common one as the latter marks real value as computed
+	resource.CustomizeDiff = diffSequence(diffSources, diffString, diffChunks, diffResource)
+	resource.Create = crudSequence(resource.Create, updateString, updateChunks, encryptDesired)
+	resource.Update = crudSequence(resource.Update, updateString, updateChunks, encryptDesired)
+
+	return withStateUpgraders(resource)
 }
 
 func resourceStatefulMap() *schema.Resource {
-	return resourceFactory(schema.TypeMap)
+	resource := resourceFactory(schema.TypeMap)
+
+	// "Inputs"
+	resource.Schema[FieldOmitEmpty] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldKeyModes] = &schema.Schema{
+		Type:         schema.TypeMap,
+		Optional:     true,
+		Elem:         &schema.Schema{Type: schema.TypeString},
+		ValidateFunc: validateKeyModes,
+	}
+	resource.Schema[FieldOrderedKeys] = &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem:     &schema.Schema{Type: schema.TypeString},
+	}
+	resource.Schema[FieldNumericKeys] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldValuesMultiset] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldValuePatterns] = &schema.Schema{
+		Type:         schema.TypeMap,
+		Optional:     true,
+		Elem:         &schema.Schema{Type: schema.TypeString},
+		ValidateFunc: validatePatterns,
+	}
+	resource.Schema[FieldCanonicalJsonValues] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldMerge] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+	resource.Schema[FieldIgnoreKeys] = &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem:     &schema.Schema{Type: schema.TypeString},
+	}
+	// "Outputs"
+	resource.Schema[FieldChangeReport] = &schema.Schema{
+		Type:     schema.TypeString,
+		Computed: true,
+	}
+	resource.Schema[FieldDiff] = &schema.Schema{
+		Type:     schema.TypeMap,
+		Computed: true,
+		Elem:     &schema.Schema{Type: schema.TypeString},
+	}
+	withLength(resource)
+
+	// Resource-specific diff logic has to run before the common one as the latter marks real value as computed
+	resource.CustomizeDiff = diffSequence(diffValuePatterns, diffMap, diffMapKeys, diffLength, diffResource)
+	resource.Create = crudSequence(resource.Create, updateMapKeys)
+	resource.Update = crudSequence(resource.Update, updateMapKeys)
+
+	return withStateUpgraders(resource)
+}
+
+func resourceStatefulList() *schema.Resource {
+	resource := resourceFactory(schema.TypeList)
+
+	// Order of elements is significant both for comparison and fingerprinting
+	for _, key := range []string{FieldDesired, FieldReal, FieldDiffbase, FieldNormalizedValue} {
+		resource.Schema[key].Elem = &schema.Schema{Type: schema.TypeString}
+	}
+	withLength(resource)
+	resource.CustomizeDiff = diffSequence(diffLength, diffResource)
+
+	return withStateUpgraders(resource)
+}
+
+func resourceStatefulSet() *schema.Resource {
+	resource := resourceFactory(schema.TypeSet)
+
+	// Order of elements is insignificant, sets are compared and fingerprinted as sorted lists, see normalizeValue
+	for _, key := range []string{FieldDesired, FieldReal, FieldDiffbase, FieldNormalizedValue} {
+		resource.Schema[key].Elem = &schema.Schema{Type: schema.TypeString}
+	}
+	withLength(resource)
+	resource.CustomizeDiff = diffSequence(diffLength, diffResource)
+
+	return withStateUpgraders(resource)
+}
+
+func resourceStatefulNumber() *schema.Resource {
+	resource := resourceFactory(schema.TypeFloat)
+
+	// "Inputs"
+	resource.Schema[FieldTolerance] = &schema.Schema{
+		Type:         schema.TypeFloat,
+		Optional:     true,
+		ValidateFunc: validateNonNegative,
+	}
+	resource.Schema[FieldCompare] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Default:      CompareEq,
+		ValidateFunc: validateCompare,
+	}
+
+	return withStateUpgraders(resource)
+}
+
+func resourceStatefulBool() *schema.Resource {
+	return withStateUpgraders(resourceFactory(schema.TypeBool))
+}
+
+func resourceStatefulJson() *schema.Resource {
+	resource := resourceFactory(schema.TypeString)
+
+	// "Inputs"
+	resource.Schema[FieldCanonicalize] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  true,
+	}
+
+	resource.Schema[FieldDesired].ValidateFunc = validateJson
+	resource.Schema[FieldReal].ValidateFunc = validateJson
+
+	return withStateUpgraders(resource)
+}
+
+// resourceStatefulTypedMap is a map whose values preserve their types, unlike the ones of TypeMap that are always
+// strings. It's a JSON object compared and fingerprinted in canonical form, so that parsed values are compared deeply.
+func resourceStatefulTypedMap() *schema.Resource {
+	resource := resourceStatefulJson()
+
+	resource.Schema[FieldDesired].ValidateFunc = validateJsonObject
+	resource.Schema[FieldReal].ValidateFunc = validateJsonObject
+
+	return resource
+}
+
+// withStateUpgraders registers upgrades of states written by provider versions predating the schema version. It has to
+// be applied once the resource-specific schema is complete as the upgrade is typed with it.
+func withStateUpgraders(resource *schema.Resource) *schema.Resource {
+	resource.SchemaVersion = 1
+	resource.StateUpgraders = []schema.StateUpgrader{
+		{
+			Version: 0,
+			// Attributes introduced since are simply missing from such states
+			Type:    resource.CoreConfigSchema().ImpliedType(),
+			Upgrade: upgradeStateV0,
+		},
+	}
+	return resource
+}
+
+// upgradeStateV0 backfills computed attributes introduced after the initial schema version, the rest of them, e.g. the
+// digests, are populated upon the next refresh
+func upgradeStateV0(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	if generation, _ := rawState[FieldGeneration].(float64); generation == 0 {
+		rawState[FieldGeneration] = 1
+	}
+	if rawState[FieldLastUpdated] == nil {
+		rawState[FieldLastUpdated] = rawState[FieldLastChanged]
+	}
+	if rawState[FieldIdSource] == nil {
+		rawState[FieldIdSource] = IdSourceRandomV4
+	}
+	if rawState[FieldHashChanged] == nil {
+		rawState[FieldHashChanged] = false
+	}
+	if rawState[FieldHashSource] == nil {
+		rawState[FieldHashSource] = HashSourceDesired
+	}
+	return rawState, nil
 }
 
 func resourceFactory(inputType schema.ValueType) *schema.Resource {
-	return &schema.Resource{
+	resource := &schema.Resource{
 		Create: createResource,
 		Read:   readResource,
 		Update: updateResource,
@@ -41,71 +442,1523 @@ func resourceFactory(inputType schema.ValueType) *schema.Resource {
 				Type:     inputType,
 				Optional: true,
 				Computed: true,
+				// Changes to real value are detected by CustomizeDiff. ResourceDiff.Clear cannot be used to drop them
+				// as it also drops diffs of all other fields sharing the prefix, so no inputs may be prefixed by real.
+				DiffSuppressFunc: suppressDiff,
+			},
+			FieldNonce: {
+				Type:     schema.TypeInt,
+				Optional: true,
+			},
+			FieldTransforms: {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateTransform,
+				},
+			},
+			FieldSignToken: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldGroupSize: {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validatePositive,
+			},
+			FieldTruncateHash: {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validatePositive,
+			},
+			FieldAllowedHashes: {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldRequireApproved: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldRotationPeriod: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateDuration,
+			},
+			FieldDriftGracePeriod: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateDuration,
+			},
+			FieldRequireReal: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldDebugLog: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldEphemeral: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			FieldTtl: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateDuration,
+			},
+			FieldObserveCommand: {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MinItems:      1,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{FieldReal},
+			},
+			FieldPublishCommand: {
+				Type:     schema.TypeList,
+				Optional: true,
+				MinItems: 1,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldIdInput: {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			FieldForceRecreate: {
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldKeepers: {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			// Unlike the provider options these are not prefixed with "hash" as planning the hash would drop their diffs
+			FieldDigestAlgorithm: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateHashAlgorithm,
+			},
+			FieldDigestEncoding: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateHashEncoding,
+			},
+			FieldSalt: {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			FieldPrefix: {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			FieldSuffix: {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			FieldAcceptReal: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+				// Hash of an accepted real value has to be planned while neither of these is known upon plan
+				ConflictsWith: []string{FieldEphemeral, FieldObserveCommand},
 			},
 			// "Outputs"
 			FieldHash: {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			FieldToken: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHashGrouped: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHashUrlencoded: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHashMd5: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHashSha1: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHashSha256: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldSize: {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			FieldHashRaw: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldOldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHashChanged: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldLockToken: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldStableId: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldApproved: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldIdSource: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldDrifted: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldDriftPending: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldDriftFirstObserved: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldDiffbase: {
+				Type:     inputType,
+				Computed: true,
+			},
+			FieldLastChanged: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldAge: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldRotationCount: {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			FieldRotationPending: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldNextRotation: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldRotationDue: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldSerialization: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldApplyNonce: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldIsEmpty: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldNormalizedValue: {
+				Type:     inputType,
+				Computed: true,
+			},
+			FieldEffectiveConfig: {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldRealHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldIsRealSet: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldGeneration: {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			FieldLastUpdated: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHashSource: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 		},
 	}
+	// State is derived entirely from the configuration, so the next plan and apply recompute it after import
+	resource.Importer = &schema.ResourceImporter{
+		State: importResource(resource),
+	}
+	return resource
+}
+
+// importResource populates the state of a resource imported by its id with the defaults of its arguments and the zero
+// values of its attributes but the ones derived from provider settings, so that only the desired value and the
+// attributes computed from it are missing until the next apply. Resource-specific fields are looked up upon import.
+func importResource(resource *schema.Resource) schema.StateFunc {
+	return func(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+		for key, field := range resource.Schema {
+			if field.Default != nil {
+				d.Set(key, field.Default)
+			} else if field.Computed && !field.Optional {
+				d.Set(key, field.ZeroValue())
+			}
+		}
+		d.Set(FieldHashSource, HashSourceDesired)
+		d.Set(FieldSerialization, providerConfig(m).Serialization)
+		d.Set(FieldEffectiveConfig, getEffectiveConfig(d, m))
+		return []*schema.ResourceData{d}, nil
+	}
+}
+
+// fingerprintFields lists all fields that affect the fingerprint so that it's recomputed whenever any of them changes
+var fingerprintFields = []string{
+	FieldDesired, FieldNonce, FieldTransforms, FieldOmitEmpty, FieldQuerystringValue, FieldTomlValue, FieldOrderedKeys,
+	FieldValuesMultiset, FieldHeadersValue, FieldNumericKeys, FieldEphemeral, FieldCanonicalJsonValues,
+	FieldCaseInsensitive, FieldNormalizeWhitespace, FieldIgnoreKeys, FieldTruncateHash, FieldCanonicalize,
+	FieldIgnorePattern, FieldKeepers, FieldDigestAlgorithm, FieldDigestEncoding, FieldSalt,
+	FieldPrefix, FieldSuffix,
 }
 
 func getSHA256(o interface{}) string {
 	serialized, _ := json.Marshal(o)
+	return getDigest(serialized)
+}
+
+func getDigest(serialized []byte) string {
 	h := sha256.New()
-	h.Write([]byte(serialized))
+	h.Write(serialized)
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-func getStatefulResourceFingerprint(d *schema.ResourceData) string {
-	data := d.Get(FieldDesired)
-	return getSHA256(data)
+// getSerializedHash hashes the value serialized in the format and with the algorithm configured for the provider
+func getSerializedHash(o interface{}, m interface{}) string {
+	return getConfiguredDigest(getSerialized(o, m), m)
 }
 
-func createResource(d *schema.ResourceData, m interface{}) error {
-	d.SetId(uuid.NewV4().String())
-
-	sha256hash := getStatefulResourceFingerprint(d)
-	d.Set(FieldHash, sha256hash)
+func getConfiguredDigest(serialized []byte, m interface{}) string {
+	newHash, ok := hashAlgorithms[providerConfig(m).HashAlgorithm]
+	if !ok {
+		newHash = sha256.New
+	}
+	return getEncodedDigest(serialized, m, newHash)
+}
 
-	return nil
+// getEncodedDigest hashes the value with the given algorithm, salted, keyed and encoded as configured for the provider
+func getEncodedDigest(serialized []byte, m interface{}, newHash func() hash.Hash) string {
+	config := providerConfig(m)
+	h := newHash()
+	if config.HmacKey != "" {
+		h = hmac.New(newHash, []byte(config.HmacKey))
+	}
+	h.Write([]byte(config.Salt))
+	h.Write(serialized)
+	if config.HashEncoding == HashEncodingBase64url {
+		return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-func readResource(d *schema.ResourceData, m interface{}) error {
-	sha256hash := getStatefulResourceFingerprint(d)
-	d.Set(FieldHash, sha256hash)
-	return nil
+// getDigestLength returns the length of encoded digests produced with the algorithm configured for the provider
+func getDigestLength(m interface{}) int {
+	return len(getConfiguredDigest(nil, m))
 }
 
-func updateResource(d *schema.ResourceData, m interface{}) error {
-	sha256hash := getStatefulResourceFingerprint(d)
-	d.Set(FieldHash, sha256hash)
-	return nil
+func getSerialized(o interface{}, m interface{}) []byte {
+	switch providerConfig(m).Serialization {
+	case SerializationCbor:
+		serialized, _ := getCBOR(o)
+		return serialized
+	case SerializationRaw:
+		return getRaw(o)
+	}
+	serialized, _ := json.Marshal(o)
+	return serialized
 }
 
-func deleteResource(d *schema.ResourceData, m interface{}) error {
-	return nil
+// fingerprintDigests holds the configurable hash along with the digests of the same data with fixed algorithms and
+// the size of the data in bytes once serialized
+type fingerprintDigests struct {
+	Hash   string
+	Raw    string
+	Md5    string
+	Sha1   string
+	Sha256 string
+	Size   int
 }
 
-func diffResource(d *schema.ResourceDiff, m interface{}) error {
-	desiredValue := d.Get(FieldDesired)
-	realValue, realValueIsSet := d.GetOkExists(FieldReal)
+func getStatefulResourceFingerprint(d resourceGetter, m interface{}) string {
+	return getStatefulResourceDigests(d, m).Hash
+}
 
-	if realValueIsSet {
-		if reflect.DeepEqual(desiredValue, realValue) {
-			d.Clear(FieldReal)
-		} else {
-			d.SetNewComputed(FieldReal)
-			d.SetNewComputed(FieldHash)
-		}
-	} else {
-		d.Clear(FieldReal)
+func getStatefulResourceDigests(d resourceGetter, m interface{}) fingerprintDigests {
+	m = getResourceConfig(d, m)
+	data := normalizeValue(d, getFingerprintedValue(d))
+	if keys, ok := d.GetOk(FieldOrderedKeys); ok || getBool(d, FieldNumericKeys) {
+		order, _ := keys.([]interface{})
+		data = getOrderedEntries(data, order, getBool(d, FieldNumericKeys))
 	}
-
-	if d.HasChange(FieldDesired) {
-		d.SetNewComputed(FieldHash)
+	if nonce, ok := d.GetOk(FieldNonce); ok {
+		// Nonce only affects the fingerprint and is never compared against real state
+		data = map[string]interface{}{FieldDesired: data, FieldNonce: nonce}
+	}
+	if keepers, ok := d.GetOk(FieldKeepers); ok {
+		// Unlike force_recreate keepers roll the fingerprint in place, the id stays the same
+		data = map[string]interface{}{FieldDesired: data, FieldKeepers: keepers}
+	}
+	if rotation, ok := d.GetOk(FieldRotationCount); ok {
+		// Rotation is bumped once ttl elapses so that the fingerprint changes without any changes to the config
+		data = map[string]interface{}{FieldDesired: data, FieldRotationCount: rotation}
 	}
+	if applyNonce, ok := d.GetOk(FieldApplyNonce); ok && getBool(d, FieldEphemeral) {
+		// Ephemeral resources get a fresh nonce upon every apply so that the fingerprint always changes
+		data = map[string]interface{}{FieldDesired: data, FieldApplyNonce: applyNonce}
+	}
+
+	serialized := getSerialized(data, m)
+	hash := getConfiguredDigest(serialized, m)
+	if length, ok := d.Get(FieldTruncateHash).(int); ok && length > 0 && length < len(hash) {
+		hash = hash[:length]
+	}
+	if getBool(d, FieldDebugLog) {
+		logFingerprint(d, m, serialized, hash)
+	}
+	return fingerprintDigests{
+		Hash:   d.Get(FieldPrefix).(string) + hash + d.Get(FieldSuffix).(string),
+		Raw:    hash,
+		Md5:    getEncodedDigest(serialized, m, md5.New),
+		Sha1:   getEncodedDigest(serialized, m, sha1.New),
+		Sha256: getEncodedDigest(serialized, m, sha256.New),
+		Size:   len(serialized),
+	}
+}
+
+// getDigestFields maps the attributes computed along with the hash to their values
+func getDigestFields(digests fingerprintDigests) map[string]interface{} {
+	return map[string]interface{}{
+		FieldHashMd5:    digests.Md5,
+		FieldHashSha1:   digests.Sha1,
+		FieldHashSha256: digests.Sha256,
+		FieldHashRaw:    digests.Raw,
+		FieldSize:       digests.Size,
+	}
+}
+
+// digestFields lists all attributes computed along with the hash, some are prefixed with the hash so they must be
+// marked after it
+var digestFields = []string{FieldHashMd5, FieldHashSha1, FieldHashSha256, FieldSize, FieldHashRaw}
+
+// getFingerprintedValue returns real value in place of the desired one once it's accepted, which is only possible upon
+// plan as real value never makes it to the state, see isHashPlanned
+func getFingerprintedValue(d resourceGetter) interface{} {
+	if diff, ok := d.(*schema.ResourceDiff); ok && isRealAccepted(diff) {
+		return d.Get(FieldReal)
+	}
+	return d.Get(FieldDesired)
+}
+
+// isRealAccepted tells whether real value is set and is to be fingerprinted in place of the desired one
+func isRealAccepted(d *schema.ResourceDiff) bool {
+	_, realValueIsSet := d.GetOkExists(FieldReal)
+	return getBool(d, FieldAcceptReal) && realValueIsSet && d.NewValueKnown(FieldReal)
+}
+
+// isHashPlanned tells whether the hash cannot be computed upon apply or refresh so the planned or the stored one has to
+// be used instead, see setFingerprintNewComputed
+func isHashPlanned(d resourceGetter) bool {
+	return isCiphertext(d.Get(FieldDesired)) || d.Get(FieldHashSource) == HashSourceReal
+}
+
+// logFingerprint logs the canonical serialized form being hashed, unless it's confidential, to troubleshoot mismatches
+func logFingerprint(d resourceGetter, m interface{}, serialized []byte, hash string) {
+	content := fmt.Sprintf("%q", serialized)
+	if providerConfig(m).Serialization == SerializationCbor {
+		content = fmt.Sprintf("%x", serialized)
+	}
+	if key, _ := d.Get(FieldEncryptPublicKey).(string); key != "" {
+		content = "(redacted)"
+	}
+	log.Printf("[DEBUG] stateful: resource '%s' hashed %s %s as '%s'", d.Id(), providerConfig(m).Serialization, content, hash)
+}
+
+// setFingerprint computes the fingerprint and sets the hash along with all the attributes derived from it
+func setFingerprint(d *schema.ResourceData, m interface{}) {
+	if getBool(d, FieldEphemeral) {
+		d.Set(FieldApplyNonce, uuid.NewV4().String())
+	} else {
+		d.Set(FieldApplyNonce, "")
+	}
+	if isHashPlanned(d) {
+		// Neither plaintext of unchanged encrypted desired value nor accepted real value are available, the planned
+		// hash is used instead
+		setHash(d, m, d.Get(FieldHash).(string))
+		return
+	}
+	digests := getStatefulResourceDigests(d, m)
+	setHash(d, m, digests.Hash)
+	setDigests(d, digests)
+}
+
+func setDigests(d *schema.ResourceData, digests fingerprintDigests) {
+	for key, value := range getDigestFields(digests) {
+		d.Set(key, value)
+	}
+}
+
+// refreshFingerprint reuses the stored hash as fingerprint inputs only change with updates that recompute it anyway,
+// unless the hash was computed with another serialization, while the attributes derived from it are always refreshed.
+// The stored hash is verified against the one derived from the state upon every refresh, a mismatching one fails the
+// refresh when strict_hash_check is enabled and is repaired in place otherwise.
+func refreshFingerprint(d *schema.ResourceData, m interface{}) error {
+	config := getResourceConfig(d, m)
+	hash := d.Get(FieldHash).(string)
+	effectiveConfig := d.Get(FieldEffectiveConfig).(map[string]interface{})
+	// Neither salt nor HMAC key are ever recorded, so salted and keyed hashes are always recomputed
+	reconfigured := d.Get(FieldSerialization) != config.Serialization || config.Salt != "" || config.HmacKey != ""
+	for key, value := range map[string]string{
+		"algorithm":        config.HashAlgorithm,
+		"encoding":         config.HashEncoding,
+		"salt_present":     strconv.FormatBool(config.Salt != ""),
+		"hmac_key_present": strconv.FormatBool(config.HmacKey != ""),
+	} {
+		// States predating the setting have no record of it
+		if previous, ok := effectiveConfig[key]; ok && previous != value {
+			reconfigured = true
+		}
+	}
+
+	if hash == "" || reconfigured && !isHashPlanned(d) {
+		digests := getStatefulResourceDigests(d, m)
+		setDigests(d, digests)
+		setHash(d, m, digests.Hash)
+		return nil
+	}
+	if !isHashPlanned(d) {
+		digests := getStatefulResourceDigests(d, m)
+		if digests.Hash != hash {
+			// State was edited by hand or written by a buggy version
+			if config.StrictHashCheck {
+				return fmt.Errorf("hash '%s' of resource '%s' doesn't match '%s' derived from its state",
+					hash, d.Id(), digests.Hash)
+			}
+			log.Printf("[WARN] stateful: repairing hash '%s' of resource '%s' as '%s' derived from its state",
+				hash, d.Id(), digests.Hash)
+			// Repaired hash is the one the resource should have had all along rather than a change of it
+			setDigests(d, digests)
+			setHashAttributes(d, m, digests.Hash)
+			return nil
+		}
+		setDigests(d, digests)
+	}
+	setHash(d, m, hash)
+	return nil
+}
+
+func setHash(d *schema.ResourceData, m interface{}, sha256hash string) {
+	// Hash may be already planned, so the previous one is taken from the state
+	previous, _ := d.GetChange(FieldHash)
+	if previous != sha256hash || d.Get(FieldLastChanged) == "" {
+		d.Set(FieldLastChanged, timeNow().UTC().Format(time.RFC3339))
+		d.Set(FieldDiffbase, d.Get(FieldDesired))
+	}
+	if previous != sha256hash {
+		// Empty upon creation
+		d.Set(FieldOldHash, previous)
+	}
+	// Generation starts at 1 upon creation, states predating it start there as well
+	if generation := d.Get(FieldGeneration).(int); previous != sha256hash || generation == 0 {
+		d.Set(FieldGeneration, generation+1)
+	}
+	setHashAttributes(d, m, sha256hash)
+}
+
+// setHashAttributes sets the hash along with the attributes derived from it without tracking it as a change
+func setHashAttributes(d *schema.ResourceData, m interface{}, sha256hash string) {
+	d.Set(FieldAge, getAge(d))
+	nextRotation, rotationDue := getRotationSchedule(d)
+	d.Set(FieldNextRotation, nextRotation)
+	d.Set(FieldRotationDue, rotationDue)
+	d.Set(FieldHash, sha256hash)
+	d.Set(FieldSerialization, providerConfig(m).Serialization)
+	if desired := d.Get(FieldDesired); !isCiphertext(desired) {
+		d.Set(FieldIsEmpty, isZeroValue(desired))
+		d.Set(FieldNormalizedValue, getNormalizedValue(d))
+	}
+	d.Set(FieldEffectiveConfig, getEffectiveConfig(d, m))
+	for key, value := range getDerivedFields(d, m, sha256hash) {
+		d.Set(key, value)
+	}
+}
+
+// setFingerprintNewComputed marks the hash along with all the attributes derived from it to be recomputed
+func setFingerprintNewComputed(d *schema.ResourceDiff, m interface{}) {
+	if isPlaintextPlanned(d) || isRealAccepted(d) {
+		// Plaintext of an encrypted desired value as well as an accepted real value are only available upon plan, so
+		// the hash is computed right away. It has to be set before the attributes it's a prefix of as it drops their
+		// diffs.
+		digests := getStatefulResourceDigests(d, m)
+		d.SetNew(FieldHash, digests.Hash)
+		for key, value := range getDigestFields(digests) {
+			d.SetNew(key, value)
+		}
+	} else {
+		d.SetNewComputed(FieldHash)
+		for _, key := range digestFields {
+			d.SetNewComputed(key)
+		}
+	}
+	d.SetNewComputed(FieldHashChanged)
+	d.SetNewComputed(FieldOldHash)
+	d.SetNewComputed(FieldIsEmpty)
+	d.SetNewComputed(FieldNormalizedValue)
+	d.SetNewComputed(FieldLastChanged)
+	d.SetNewComputed(FieldDiffbase)
+	d.SetNewComputed(FieldAge)
+	d.SetNewComputed(FieldNextRotation)
+	d.SetNewComputed(FieldRotationDue)
+	for _, key := range derivedFields {
+		d.SetNewComputed(key)
+	}
+}
+
+// derivedFields lists all attributes that are derived from the hash
+var derivedFields = []string{
+	FieldToken, FieldHashGrouped, FieldHashUrlencoded, FieldLockToken, FieldApproved, FieldStableId,
+}
+
+func getDerivedFields(d resourceGetter, m interface{}, hash string) map[string]interface{} {
+	return map[string]interface{}{
+		FieldToken:          getFingerprintToken(d, m, hash),
+		FieldHashGrouped:    getGroupedHash(hash, d.Get(FieldGroupSize).(int)),
+		FieldHashUrlencoded: url.QueryEscape(hash),
+		FieldLockToken:      getLockToken(d.Id(), hash),
+		// Content identity that, unlike the id, changes along with the content but not along with the affixes
+		FieldStableId: getRawHash(d, hash),
+		FieldApproved: isHashApproved(d, hash),
+	}
+}
+
+// getRawHash strips prefix and suffix off the hash, see hash_raw
+func getRawHash(d resourceGetter, hash string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(hash, d.Get(FieldPrefix).(string)), d.Get(FieldSuffix).(string))
+}
+
+// getNormalizedValue returns desired value as it's compared and fingerprinted, unless it cannot be represented with
+// the type of desired value, e.g. maps turned into lists by values_multiset
+func getNormalizedValue(d resourceGetter) interface{} {
+	if key, _ := d.Get(FieldEncryptPublicKey).(string); key != "" {
+		// Plaintext of a value to be encrypted must not be revealed
+		return nil
+	}
+	desired := d.Get(FieldDesired)
+	value := normalizeValue(d, desired)
+	if reflect.TypeOf(value) != reflect.TypeOf(desired) {
+		if _, isSet := desired.(*schema.Set); !isSet {
+			return nil
+		}
+	}
+	return value
+}
+
+// getEffectiveConfig describes the settings used to compute the hash after resolving the provider configuration and
+// the precedence of resource options, e.g. transforms superseding individual normalization options
+func getEffectiveConfig(d resourceGetter, m interface{}) map[string]interface{} {
+	config := getResourceConfig(d, m)
+	transformsSource := "options"
+	if _, ok := d.GetOk(FieldTransforms); ok {
+		transformsSource = FieldTransforms
+	}
+	return map[string]interface{}{
+		"algorithm":         config.HashAlgorithm,
+		"encoding":          config.HashEncoding,
+		FieldSerialization:  config.Serialization,
+		"hmac_key_present":  strconv.FormatBool(config.HmacKey != ""),
+		"salt_present":      strconv.FormatBool(config.Salt != ""),
+		FieldFailOnDrift:    strconv.FormatBool(config.FailOnDrift),
+		FieldTransforms:     strings.Join(getTransforms(d), ","),
+		"transforms_source": transformsSource,
+	}
+}
+
+// getResourceConfig returns the provider configuration with the hash settings overridden by the resource, if set
+func getResourceConfig(d resourceGetter, m interface{}) *Config {
+	config := *providerConfig(m)
+	if algorithm, ok := d.GetOk(FieldDigestAlgorithm); ok {
+		config.HashAlgorithm = algorithm.(string)
+	}
+	if encoding, ok := d.GetOk(FieldDigestEncoding); ok {
+		config.HashEncoding = encoding.(string)
+	}
+	if salt, ok := d.GetOk(FieldSalt); ok {
+		config.Salt = salt.(string)
+	}
+	return &config
+}
+
+// isHashApproved checks whether the hash is among the allowed ones
+func isHashApproved(d resourceGetter, hash string) bool {
+	for _, allowed := range d.Get(FieldAllowedHashes).([]interface{}) {
+		if allowed == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// getGroupedHash splits the hash into groups of the given size joined with a dash, e.g. "a1b2-c3d4-e5f6"
+func getGroupedHash(hash string, size int) string {
+	if size <= 0 || hash == "" {
+		return ""
+	}
+	var groups []string
+	for len(hash) > size {
+		groups = append(groups, hash[:size])
+		hash = hash[size:]
+	}
+	return strings.Join(append(groups, hash), "-")
+}
+
+// getLockToken combines the instance ID with the hash so that the token only changes along with the content but is
+// unique per instance even when the content is identical
+func getLockToken(id string, hash string) string {
+	if id == "" || hash == "" {
+		return ""
+	}
+	return getDigest([]byte(id + ":" + hash))
+}
+
+func getFingerprintToken(d resourceGetter, m interface{}, hash string) string {
+	if !getBool(d, FieldSignToken) {
+		return ""
+	}
+	return getToken(hash, providerConfig(m).HmacKey)
+}
+
+func createResource(d *schema.ResourceData, m interface{}) error {
+	id, idIsSet := d.GetOk(FieldIdInput)
+	switch {
+	case idIsSet:
+		d.SetId(id.(string))
+		d.Set(FieldIdSource, IdSourceInput)
+	case providerConfig(m).IdFromHash:
+		d.Set(FieldIdSource, IdSourceHash)
+	default:
+		d.SetId(uuid.NewV4().String())
+		d.Set(FieldIdSource, IdSourceRandomV4)
+	}
+
+	setFingerprint(d, m)
+	if d.Id() == "" {
+		// The hash is only known once fingerprinted, attributes derived from it depend on the id as well
+		hash := d.Get(FieldHash).(string)
+		d.SetId(hash)
+		for key, value := range getDerivedFields(d, m, hash) {
+			d.Set(key, value)
+		}
+	}
+	d.Set(FieldHashChanged, true)
+	d.Set(FieldLastUpdated, timeNow().UTC().Format(time.RFC3339))
+
+	return publishHash(d)
+}
+
+func readResource(d *schema.ResourceData, m interface{}) error {
+	// Imported resources have no desired value until the next apply, the hash is computed then
+	if _, ok := d.GetOkExists(FieldDesired); !ok {
+		return nil
+	}
+	if err := refreshFingerprint(d, m); err != nil {
+		return err
+	}
+
+	rotationPending, err := isRotationDue(d)
+	if err != nil {
+		return err
+	}
+	d.Set(FieldRotationPending, rotationPending)
+
+	if command, ok := d.GetOk(FieldObserveCommand); ok {
+		realValue, err := getCommandRealValue(providerConfig(m), command.([]interface{}), d.Get(FieldDesired))
+		if err != nil {
+			return err
+		}
+		d.Set(FieldReal, realValue)
+		d.Set(FieldRealHash, getRealHash(d))
+		d.Set(FieldDrifted, isRefreshedRealDrifted(d))
+	}
+
+	return nil
+}
+
+// isRefreshedRealDrifted tells whether the real value reported by observe_command upon refresh diverges from the
+// desired one, the same way diffResource does. Drift is only confirmed by the plan while it's debounced, see
+// debounceDrift, and neither accepted real values nor encrypted desired ones are compared. Real value is read back from
+// the state so that it's typed the same way as upon plan rather than as parsed from the command output.
+func isRefreshedRealDrifted(d *schema.ResourceData) bool {
+	drifted := d.Get(FieldDrifted).(bool)
+	desired := d.Get(FieldDesired)
+	if d.Get(FieldHashSource) == HashSourceReal || isCiphertext(desired) {
+		return drifted
+	}
+	if isRealMatching(d, normalizeValue(d, desired), d.Get(FieldReal)) {
+		return false
+	}
+	if _, ok := d.GetOk(FieldDriftGracePeriod); ok {
+		return drifted
+	}
+	return true
+}
+
+// getRealHash hashes the last observed real value as is, empty when it's not set
+func getRealHash(d resourceGetter) string {
+	realValue, ok := d.GetOk(FieldReal)
+	if !ok {
+		return ""
+	}
+	return getValueHash(realValue)
+}
+
+// getValueHash hashes the value as is, sets are hashed as lists of their elements
+func getValueHash(value interface{}) string {
+	if set, ok := value.(*schema.Set); ok {
+		value = set.List()
+	}
+	return getSHA256(value)
+}
+
+// getCommandRealValue runs the command and parses its output according to the type of the desired value: strings are
+// used as is (sans trailing newline) while maps and lists are expected to be encoded as JSON objects with string
+// values and JSON arrays of strings (lists and sets) respectively
+func getCommandRealValue(config *Config, command []interface{}, desired interface{}) (interface{}, error) {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = arg.(string)
+	}
+
+	output, err := config.commands.run(args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch desired.(type) {
+	case map[string]interface{}:
+		var realValue map[string]string
+		if err := json.Unmarshal(output, &realValue); err != nil {
+			return nil, fmt.Errorf("command %q output is not a JSON object with string values: %s", args, err)
+		}
+		return realValue, nil
+	case []interface{}, *schema.Set:
+		var realValue []string
+		if err := json.Unmarshal(output, &realValue); err != nil {
+			return nil, fmt.Errorf("command %q output is not a JSON array of strings: %s", args, err)
+		}
+		return realValue, nil
+	case float64:
+		realValue, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+		if err != nil {
+			return nil, fmt.Errorf("command %q output is not a number: %s", args, err)
+		}
+		return realValue, nil
+	case bool:
+		realValue, err := strconv.ParseBool(strings.TrimSpace(string(output)))
+		if err != nil {
+			return nil, fmt.Errorf("command %q output is not a boolean: %s", args, err)
+		}
+		return realValue, nil
+	default:
+		return strings.TrimSuffix(string(output), "\n"), nil
+	}
+}
+
+func updateResource(d *schema.ResourceData, m interface{}) error {
+	previousHash, _ := d.GetChange(FieldHash)
+	setFingerprint(d, m)
+	d.Set(FieldHashChanged, d.Get(FieldHash) != previousHash)
+	// Unlike last_changed it only tracks changes of the desired value itself, see diffResource
+	if d.HasChange(FieldDesired) {
+		d.Set(FieldLastUpdated, timeNow().UTC().Format(time.RFC3339))
+	}
+
+	// Resets the hash of the real value once it's unset, see diffResource
+	if !d.Get(FieldIsRealSet).(bool) {
+		d.Set(FieldRealHash, "")
+	}
+	// Resets the time drift was first observed at once it's gone, see debounceDrift
+	if !d.Get(FieldDrifted).(bool) && !d.Get(FieldDriftPending).(bool) {
+		d.Set(FieldDriftFirstObserved, "")
+	}
+	return publishHash(d)
+}
+
+// publishHash passes the hash to publish_command, if set, both as the last argument and via stdin. Unlike
+// observe_command it's never memoized as it's run for its side effects.
+func publishHash(d *schema.ResourceData) error {
+	command, ok := d.GetOk(FieldPublishCommand)
+	if !ok {
+		return nil
+	}
+	hash := d.Get(FieldHash).(string)
+
+	args := make([]string, 0, len(command.([]interface{}))+1)
+	for _, arg := range command.([]interface{}) {
+		args = append(args, arg.(string))
+	}
+	_, err := runCommandWithInput(append(args, hash), hash+"\n")
+	return err
+}
+
+func deleteResource(d *schema.ResourceData, m interface{}) error {
+	return nil
+}
+
+func diffResource(d *schema.ResourceDiff, m interface{}) error {
+	desiredValue := normalizeValue(d, d.Get(FieldDesired))
+	realValue, realValueIsSet := d.GetOkExists(FieldReal)
+
+	if getBool(d, FieldRequireReal) && !realValueIsSet && d.NewValueKnown(FieldReal) && !isRealSourced(d) {
+		return fmt.Errorf("'%s' of resource '%s' is not set while '%s' is enabled", FieldReal, d.Id(), FieldRequireReal)
+	}
+
+	// Captured before real is marked as computed upon drift
+	realHash, realHashKnown := getRealHash(d), d.NewValueKnown(FieldReal)
+	// Accepted real value is fingerprinted in place of the desired one instead of being compared against it
+	accepted := isRealAccepted(d)
+	drifted := realValueIsSet && !accepted && !isRealMatching(d, desiredValue, realValue)
+	drifted = debounceDrift(d, drifted)
+	if drifted {
+		logDrift(d, m, realHash)
+		d.SetNewComputed(FieldReal)
+		setFingerprintNewComputed(d, m)
+	}
+	d.SetNew(FieldDrifted, drifted)
+
+	if drifted && providerConfig(m).FailOnDrift {
+		return getDriftError(d)
+	}
+
+	for _, key := range fingerprintFields {
+		if d.HasChange(key) && !(key == FieldDesired && isEncryptedUnchanged(d)) {
+			setFingerprintNewComputed(d, m)
+			d.SetNewComputed(FieldGeneration)
+		}
+	}
+	if d.HasChange(FieldDesired) && !isEncryptedUnchanged(d) {
+		d.SetNewComputed(FieldLastUpdated)
+	}
+
+	hashSource := HashSourceDesired
+	if accepted {
+		hashSource = HashSourceReal
+	}
+	if hashSource != d.Get(FieldHashSource) || accepted && getStatefulResourceFingerprint(d, m) != d.Get(FieldHash) {
+		setFingerprintNewComputed(d, m)
+		d.SetNewComputed(FieldGeneration)
+	}
+
+	if getBool(d, FieldEphemeral) {
+		d.SetNewComputed(FieldApplyNonce)
+		setFingerprintNewComputed(d, m)
+		d.SetNewComputed(FieldGeneration)
+	}
+
+	if d.HasChange(FieldRotationPeriod) {
+		d.SetNewComputed(FieldNextRotation)
+		d.SetNewComputed(FieldRotationDue)
+	}
+
+	if d.Get(FieldRotationPending).(bool) {
+		d.SetNew(FieldRotationCount, d.Get(FieldRotationCount).(int)+1)
+		d.SetNew(FieldRotationPending, false)
+		setFingerprintNewComputed(d, m)
+		d.SetNewComputed(FieldGeneration)
+	}
+
+	if getBool(d, FieldRequireApproved) && d.NewValueKnown(FieldDesired) {
+		if hash := getStatefulResourceFingerprint(d, m); !isHashApproved(d, hash) {
+			return fmt.Errorf("hash '%s' is not among '%s' while '%s' is enabled", hash, FieldAllowedHashes, FieldRequireApproved)
+		}
+	}
+
+	if getBool(d, FieldSignToken) {
+		if providerConfig(m).HmacKey == "" {
+			return fmt.Errorf("'%s' requires provider's '%s' to be set", FieldSignToken, FieldHmacKey)
+		}
+	}
+	if config := getResourceConfig(d, m); d.Get(FieldTruncateHash).(int) > getDigestLength(config) {
+		return fmt.Errorf("'%s' must not exceed the length of '%s' digests (%d), got %d",
+			FieldTruncateHash, config.HashAlgorithm, getDigestLength(config), d.Get(FieldTruncateHash).(int))
+	}
+	// Resources identified by their hash are replaced whenever it diverges from the id so that the id follows it, be it
+	// due to changes of the inputs, provider settings, rotation or a repaired hash
+	if d.Get(FieldIdSource) == IdSourceHash && !isIdFollowingHash(d, m) {
+		setFingerprintNewComputed(d, m)
+		d.SetNewComputed(FieldGeneration)
+		// A hash planned right away may match the stored one that already diverges from the id
+		key := FieldHash
+		if !d.HasChange(FieldHash) {
+			key = FieldGeneration
+		}
+		if err := d.ForceNew(key); err != nil {
+			return err
+		}
+	}
+	// Set after the hash as it drops the diff of the attributes it's a prefix of
+	if hashSource != d.Get(FieldHashSource) {
+		d.SetNew(FieldHashSource, hashSource)
+	}
+	// Retained real value is stored in the state as is, without affecting the hash
+	if providerConfig(m).RetainReal && !drifted && realValueIsSet && realHashKnown && !isRealSourced(d) {
+		if old, _ := d.GetChange(FieldReal); getValueHash(old) != realHash {
+			d.SetNew(FieldReal, realValue)
+		}
+	}
+	// Real value never makes it to the state as its diff is suppressed, so its hash cannot be computed upon apply and is
+	// planned right away, after real is marked as computed as that drops the diff of real_hash too. Empty computed
+	// strings cannot be planned, so real_hash is reset upon apply once real is unset as recorded by is_real_set. When
+	// real is sourced by observe_command it's unknown here and real_hash is set upon refresh instead.
+	realIsSet := realValueIsSet || !realHashKnown || isRealSourced(d)
+	if realIsSet != d.Get(FieldIsRealSet) {
+		d.SetNew(FieldIsRealSet, realIsSet)
+	}
+	if realHashKnown && realHash != "" && realHash != d.Get(FieldRealHash) {
+		d.SetNew(FieldRealHash, realHash)
+	} else if !realIsSet && d.Get(FieldRealHash) != "" {
+		d.SetNewComputed(FieldRealHash)
+	}
+	if effectiveConfig := getEffectiveConfig(d, m); !reflect.DeepEqual(d.Get(FieldEffectiveConfig), effectiveConfig) {
+		d.SetNew(FieldEffectiveConfig, effectiveConfig)
+	}
+	// Derived attributes have to be recomputed when their options change, e.g. signing is toggled or the key is rotated
+	for key, value := range getDerivedFields(d, m, d.Get(FieldHash).(string)) {
+		if d.Get(key) != value {
+			d.SetNewComputed(key)
+		}
+	}
+
+	return nil
+}
+
+// isIdFollowingHash checks whether the hash planned for a resource identified by its hash matches the id, hashes that
+// cannot be computed upon plan are assumed to differ
+func isIdFollowingHash(d *schema.ResourceDiff, m interface{}) bool {
+	for _, key := range fingerprintFields {
+		if !d.NewValueKnown(key) {
+			return false
+		}
+	}
+	if getBool(d, FieldEphemeral) || getBool(d, FieldAcceptReal) && !d.NewValueKnown(FieldReal) {
+		return false
+	}
+	return getStatefulResourceFingerprint(d, m) == d.Id()
+}
+
+// isRealSourced checks whether real state is reported by means other than the real argument
+func isRealSourced(d resourceGetter) bool {
+	if _, ok := d.GetOk(FieldObserveCommand); ok {
+		return true
+	}
+	sources, _ := d.Get(FieldSources).(map[string]interface{})
+	return len(sources) > 0
+}
+
+func validatePositive(v interface{}, k string) ([]string, []error) {
+	if v.(int) <= 0 {
+		return nil, []error{fmt.Errorf("'%s' must be positive, got %d", k, v.(int))}
+	}
+	return nil, nil
+}
+
+func validateNonNegative(v interface{}, k string) ([]string, []error) {
+	if v.(float64) < 0 {
+		return nil, []error{fmt.Errorf("'%s' must not be negative, got %g", k, v.(float64))}
+	}
+	return nil, nil
+}
+
+func validateJson(v interface{}, k string) ([]string, []error) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(v.(string)), &document); err != nil {
+		if syntaxError, ok := err.(*json.SyntaxError); ok {
+			return nil, []error{fmt.Errorf("'%s' must be valid JSON: %s (offset %d)", k, err, syntaxError.Offset)}
+		}
+		return nil, []error{fmt.Errorf("'%s' must be valid JSON: %s", k, err)}
+	}
+	return nil, nil
+}
+
+func validateJsonObject(v interface{}, k string) ([]string, []error) {
+	if warnings, errors := validateJson(v, k); len(errors) > 0 {
+		return warnings, errors
+	}
+	var document interface{}
+	json.Unmarshal([]byte(v.(string)), &document)
+	if _, ok := document.(map[string]interface{}); !ok {
+		return nil, []error{fmt.Errorf("'%s' must be a JSON object, got '%s'", k, v)}
+	}
+	return nil, nil
+}
+
+func validateCompare(v interface{}, k string) ([]string, []error) {
+	switch v.(string) {
+	case CompareEq, CompareGte, CompareLte:
+		return nil, nil
+	}
+	return nil, []error{fmt.Errorf("'%s' must be one of '%s', '%s' or '%s', got '%s'", k, CompareEq, CompareGte, CompareLte, v)}
+}
+
+func validatePatterns(v interface{}, k string) ([]string, []error) {
+	var errs []error
+	for key, pattern := range v.(map[string]interface{}) {
+		if _, err := regexp.Compile(pattern.(string)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid pattern for key '%s': %s", k, key, err))
+		}
+	}
+	return nil, errs
+}
+
+func validateFraction(v interface{}, k string) ([]string, []error) {
+	if v.(float64) <= 0 || v.(float64) > 1 {
+		return nil, []error{fmt.Errorf("'%s' must be greater than 0 and not greater than 1, got %g", k, v.(float64))}
+	}
+	return nil, nil
+}
+
+func suppressDiff(k, old, new string, d *schema.ResourceData) bool {
+	return true
+}
+
+// debounceDrift only reports drift once it persists beyond the grace period, recording when it was first observed and
+// whether it's pending meanwhile
+func debounceDrift(d *schema.ResourceDiff, drifted bool) bool {
+	grace, ok := d.GetOk(FieldDriftGracePeriod)
+	if !ok || !d.NewValueKnown(FieldReal) {
+		return drifted
+	}
+
+	observed := d.Get(FieldDriftFirstObserved).(string)
+	if !drifted {
+		if observed != "" {
+			// Terraform treats empty computed strings as unknown, so the time is reset upon apply instead
+			d.SetNewComputed(FieldDriftFirstObserved)
+		}
+		d.SetNew(FieldDriftPending, false)
+		return false
+	}
+
+	now := timeNow()
+	if observed == "" {
+		d.SetNew(FieldDriftFirstObserved, now.UTC().Format(time.RFC3339))
+		d.SetNew(FieldDriftPending, true)
+		return false
+	}
+
+	duration, _ := time.ParseDuration(grace.(string))
+	observedAt, err := time.Parse(time.RFC3339, observed)
+	persisted := err != nil || now.Sub(observedAt) >= duration
+	d.SetNew(FieldDriftPending, !persisted)
+	return persisted
+}
+
+// logDrift warns about drift detected upon plan, unless disabled for the provider, as CustomizeDiff cannot return
+// warning diagnostics. Must be called before the hash is marked as computed.
+func logDrift(d resourceGetter, m interface{}, realHash string) {
+	if providerConfig(m).WarnOnDrift {
+		log.Printf("[WARN] stateful: real state of resource '%s' diverges from the desired one (hash '%s', real_hash '%s')",
+			d.Id(), d.Get(FieldHash), realHash)
+	}
+}
+
+func getDriftError(d *schema.ResourceDiff) error {
+	return fmt.Errorf("real state of resource '%s' diverges from the desired one while '%s' is enabled", d.Id(), FieldFailOnDrift)
+}
+
+// diffSequence chains multiple CustomizeDiff functions so that resources can extend the common diff logic
+func diffSequence(funcs ...schema.CustomizeDiffFunc) schema.CustomizeDiffFunc {
+	return func(d *schema.ResourceDiff, m interface{}) error {
+		for _, f := range funcs {
+			if err := f(d, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// crudSequence chains multiple CRUD functions so that resources can extend the common logic
+func crudSequence(funcs ...func(*schema.ResourceData, interface{}) error) func(*schema.ResourceData, interface{}) error {
+	return func(d *schema.ResourceData, m interface{}) error {
+		for _, f := range funcs {
+			if err := f(d, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// getSourcesDrift compares already normalized desired value against every named real source, normalized the same way
+// as real is, and reports per-source drift along with an overall flag that is set when any of the sources diverges.
+func getSourcesDrift(d resourceGetter, desired interface{}, sources map[string]interface{}) (map[string]interface{}, bool) {
+	perSource := make(map[string]interface{}, len(sources))
+	anyDrift := false
+	for name, real := range sources {
+		drift := !isRealMatching(d, desired, real)
+		perSource[name] = drift
+		anyDrift = anyDrift || drift
+	}
+	return perSource, anyDrift
+}
+
+func diffSources(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown(FieldSources) || !d.NewValueKnown(FieldDesired) {
+		d.SetNewComputed(FieldPerSourceDrift)
+		d.SetNewComputed(FieldAnyDrift)
+		return nil
+	}
+
+	desired := normalizeValue(d, d.Get(FieldDesired))
+	perSource, anyDrift := getSourcesDrift(d, desired, d.Get(FieldSources).(map[string]interface{}))
+	if len(perSource) > 0 || len(d.Get(FieldPerSourceDrift).(map[string]interface{})) > 0 {
+		d.SetNew(FieldPerSourceDrift, perSource)
+	} else {
+		// Computed maps that are empty are never persisted and would otherwise show up as computed on every plan
+		d.Clear(FieldPerSourceDrift)
+	}
+	d.SetNew(FieldAnyDrift, anyDrift)
+
+	if anyDrift {
+		if providerConfig(m).FailOnDrift {
+			return getDriftError(d)
+		}
+		setFingerprintNewComputed(d, m)
+	}
+
+	return nil
+}
+
+// diffString renders a human-readable unified diff between desired and real strings when they diverge
+func diffString(d *schema.ResourceDiff, m interface{}) error {
+	if pattern := d.Get(FieldIgnorePattern).(string); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("'%s' must be a valid regular expression: %s", FieldIgnorePattern, err)
+		}
+	}
+
+	if !d.NewValueKnown(FieldDesired) || !d.NewValueKnown(FieldReal) {
+		d.SetNewComputed(FieldDiff)
+		return nil
+	}
+
+	diff := ""
+	realValue, realValueIsSet := d.GetOkExists(FieldReal)
+	// Accepted real value is not compared against the desired one, see diffResource
+	if realValueIsSet && d.Get(FieldEncryptPublicKey) == "" && !isRealAccepted(d) {
+		desiredValue := normalizeValue(d, d.Get(FieldDesired))
+		if !isRealMatching(d, desiredValue, realValue) {
+			diff = getUnifiedDiff(FieldDesired, FieldReal, desiredValue.(string), normalizeValue(d, realValue).(string))
+		}
+	}
+
+	if diff != "" {
+		d.SetNew(FieldDiff, diff)
+	} else if d.Get(FieldDiff) != "" {
+		// Terraform treats empty computed strings as unknown, so the diff is reset upon apply instead
+		d.SetNewComputed(FieldDiff)
+	}
+
+	return nil
+}
+
+// diffChunks plans content-defined chunk hashes of desired value when cdc_fingerprint is enabled
+func diffChunks(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown(FieldDesired) || !d.NewValueKnown(FieldCdcFingerprint) {
+		d.SetNewComputed(FieldChunkHashes)
+		d.SetNewComputed(FieldChunkCount)
+		return nil
+	}
+
+	hashes := getDesiredChunkHashes(d)
+	if !reflect.DeepEqual(hashes, d.Get(FieldChunkHashes)) {
+		d.SetNew(FieldChunkHashes, hashes)
+		d.SetNew(FieldChunkCount, len(hashes))
+	}
+	return nil
+}
+
+// getDesiredChunkHashes returns chunk hashes of normalized desired value or none when cdc_fingerprint is disabled
+func getDesiredChunkHashes(d resourceGetter) []interface{} {
+	value, ok := normalizeValue(d, d.Get(FieldDesired)).(string)
+	if !ok || !getBool(d, FieldCdcFingerprint) {
+		return []interface{}{}
+	}
+	return getChunkHashes(value)
+}
+
+// diffValuePatterns fails the plan when any value of desired doesn't match the pattern configured for its key
+func diffValuePatterns(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown(FieldDesired) || !d.NewValueKnown(FieldValuePatterns) {
+		return nil
+	}
+	patterns := d.Get(FieldValuePatterns).(map[string]interface{})
+	desired := d.Get(FieldDesired).(map[string]interface{})
+
+	keys := make([]string, 0, len(patterns))
+	for key := range patterns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := desired[key]
+		if !ok {
+			continue
+		}
+		// Patterns are validated upfront, see validatePatterns
+		if pattern := regexp.MustCompile(patterns[key].(string)); !pattern.MatchString(value.(string)) {
+			return fmt.Errorf("value '%s' of key '%s' of '%s' must match '%s' as per '%s'",
+				value, key, FieldDesired, pattern, FieldValuePatterns)
+		}
+	}
+	return nil
+}
+
+// diffMap reports every key of desired value that is changed by an update along with its old and new values
+func diffMap(d *schema.ResourceDiff, m interface{}) error {
+	if d.Id() == "" || !d.HasChange(FieldDesired) {
+		return nil
+	}
+	if !d.NewValueKnown(FieldDesired) {
+		d.SetNewComputed(FieldChangeReport)
+		return nil
+	}
+
+	old, new := d.GetChange(FieldDesired)
+	d.SetNew(FieldChangeReport, getChangeReport(old.(map[string]interface{}), new.(map[string]interface{})))
+	return nil
+}
+
+// diffMapKeys plans the per-key status of real map against the desired one when they diverge, see getKeyStatuses
+func diffMapKeys(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown(FieldDesired) || !d.NewValueKnown(FieldReal) {
+		d.SetNewComputed(FieldDiff)
+		return nil
+	}
+
+	statuses := map[string]interface{}{}
+	realValue, realValueIsSet := d.GetOkExists(FieldReal)
+	// Accepted real value is not compared against the desired one, see diffResource
+	if realValueIsSet && !isRealAccepted(d) {
+		desiredValue := normalizeValue(d, d.Get(FieldDesired))
+		if !isRealMatching(d, desiredValue, realValue) {
+			// Keys are compared as normalized for the drift detection, e.g. sans ignore_keys, unless values_multiset
+			// turns the maps into lists
+			desiredMap, desiredIsMap := desiredValue.(map[string]interface{})
+			realMap, realIsMap := normalizeValue(d, realValue).(map[string]interface{})
+			if !desiredIsMap || !realIsMap {
+				desiredMap, realMap = d.Get(FieldDesired).(map[string]interface{}), realValue.(map[string]interface{})
+			}
+			statuses = getKeyStatuses(desiredMap, realMap)
+		}
+	}
+
+	if len(statuses) > 0 {
+		d.SetNew(FieldDiff, statuses)
+	} else if len(d.Get(FieldDiff).(map[string]interface{})) > 0 {
+		// Same as for the string diff, it's reset upon apply, see updateMapKeys
+		d.SetNewComputed(FieldDiff)
+	}
+	return nil
+}
+
+// getKeyStatuses marks keys of desired value absent from the real one as missing, keys present only in the real value
+// as extra and keys with different values as changed
+func getKeyStatuses(desired, real map[string]interface{}) map[string]interface{} {
+	statuses := map[string]interface{}{}
+	for key, desiredValue := range desired {
+		if realValue, ok := real[key]; !ok {
+			statuses[key] = KeyStatusMissing
+		} else if !reflect.DeepEqual(realValue, desiredValue) {
+			statuses[key] = KeyStatusChanged
+		}
+	}
+	for key := range real {
+		if _, ok := desired[key]; !ok {
+			statuses[key] = KeyStatusExtra
+		}
+	}
+	return statuses
+}
+
+// updateMapKeys resets the per-key statuses upon apply once desired and real values are in sync, see diffMapKeys
+func updateMapKeys(d *schema.ResourceData, m interface{}) error {
+	if !d.Get(FieldDrifted).(bool) && !d.Get(FieldDriftPending).(bool) {
+		d.Set(FieldDiff, map[string]interface{}{})
+	}
+	return nil
+}
+
+// getChangeReport renders a line per changed key in lexical order, e.g. `key: "old" -> "new"`
+func getChangeReport(old, new map[string]interface{}) string {
+	keys := make(map[string]bool, len(old)+len(new))
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range new {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	for _, key := range sorted {
+		oldValue, oldExists := old[key]
+		newValue, newExists := new[key]
+		if oldExists && newExists && oldValue == newValue {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s -> %s\n", key, getReportValue(oldValue, oldExists), getReportValue(newValue, newExists))
+	}
+	return b.String()
+}
+
+func getReportValue(value interface{}, exists bool) string {
+	if !exists {
+		return "(absent)"
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// suppressEncryptedDiff hides changes between the encrypted desired value stored in the state and its plaintext
+func suppressEncryptedDiff(k, old, new string, d *schema.ResourceData) bool {
+	return isEncryptedUnchanged(d)
+}
+
+// isEncryptedUnchanged checks whether the desired value stored in the state is the encrypted form of the configured one
+func isEncryptedUnchanged(d resourceGetter) bool {
+	key, _ := d.Get(FieldEncryptPublicKey).(string)
+	old, new := d.GetChange(FieldDesired)
+	if key == "" || !isCiphertext(old) {
+		return false
+	}
+	ciphertext, err := encryptDeterministic(key, new.(string))
+	return err == nil && ciphertext == old
+}
+
+// isPlaintextPlanned checks whether desired value is going to be encrypted while its plaintext is known
+func isPlaintextPlanned(d *schema.ResourceDiff) bool {
+	key, _ := d.Get(FieldEncryptPublicKey).(string)
+	return key != "" && d.NewValueKnown(FieldEncryptPublicKey) && d.NewValueKnown(FieldDesired)
+}
+
+// encryptDesired replaces the plaintext desired value and its copy in diffbase with their encrypted form
+func encryptDesired(d *schema.ResourceData, m interface{}) error {
+	key := d.Get(FieldEncryptPublicKey).(string)
+	if key == "" {
+		return nil
+	}
+	for _, field := range []string{FieldDesired, FieldDiffbase} {
+		value := d.Get(field).(string)
+		if isCiphertext(value) {
+			continue
+		}
+		ciphertext, err := encryptDeterministic(key, value)
+		if err != nil {
+			return err
+		}
+		d.Set(field, ciphertext)
+	}
+	return nil
+}
+
+// updateString resets the diff upon apply once desired and real values are in sync, see diffString
+func updateString(d *schema.ResourceData, m interface{}) error {
+	// Diff would reveal the plaintext of an encrypted desired value
+	if !d.Get(FieldDrifted).(bool) && !d.Get(FieldDriftPending).(bool) || d.Get(FieldEncryptPublicKey) != "" {
+		d.Set(FieldDiff, "")
+	}
+	return nil
+}
+
+// withLength adds the number of elements (or keys) of desired collection to the outputs of the resource
+func withLength(resource *schema.Resource) {
+	resource.Schema[FieldLength] = &schema.Schema{
+		Type:     schema.TypeInt,
+		Computed: true,
+	}
+	resource.Create = crudSequence(resource.Create, updateLength)
+	resource.Read = crudSequence(resource.Read, updateLength)
+	resource.Update = crudSequence(resource.Update, updateLength)
+}
+
+// getLength returns the number of elements of the collection, 0 for anything else
+func getLength(value interface{}) int {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v)
+	case []interface{}:
+		return len(v)
+	case *schema.Set:
+		return v.Len()
+	}
+	return 0
+}
+
+// diffLength plans the number of elements of desired collection right away so that it can be branched on
+func diffLength(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown(FieldDesired) {
+		d.SetNewComputed(FieldLength)
+		return nil
+	}
+	if length := getLength(d.Get(FieldDesired)); d.Id() == "" || length != d.Get(FieldLength) {
+		d.SetNew(FieldLength, length)
+	}
+	return nil
+}
+
+// updateLength persists the number of elements planned by diffLength, empty collections yield 0
+func updateLength(d *schema.ResourceData, m interface{}) error {
+	d.Set(FieldLength, getLength(d.Get(FieldDesired)))
+	return nil
+}
 
+// updateChunks persists the chunk hashes planned by diffChunks
+func updateChunks(d *schema.ResourceData, m interface{}) error {
+	hashes := getDesiredChunkHashes(d)
+	d.Set(FieldChunkHashes, hashes)
+	d.Set(FieldChunkCount, len(hashes))
 	return nil
 }
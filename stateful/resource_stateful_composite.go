@@ -0,0 +1,145 @@
+package stateful
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/satori/go.uuid"
+)
+
+const FieldParts = "parts"
+const FieldPartValue = "value"
+const FieldPartWeight = "weight"
+const FieldSignificanceThreshold = "significance_threshold"
+
+const FieldSignificantHash = "significant_hash"
+
+// compositeFields lists all inputs of the composite so that outputs are recomputed whenever any of them changes
+var compositeFields = []string{FieldParts, FieldSignificanceThreshold}
+
+// compositeOutputs lists all attributes computed from the inputs of the composite
+var compositeOutputs = []string{FieldHash, FieldSignificantHash}
+
+func resourceStatefulComposite() *schema.Resource {
+	return &schema.Resource{
+		Create: createComposite,
+		Read:   readComposite,
+		Update: updateComposite,
+		Delete: deleteResource,
+
+		CustomizeDiff: diffComposite,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldParts: {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						FieldPartValue: {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						FieldPartWeight: {
+							Type:     schema.TypeFloat,
+							Optional: true,
+							Default:  1.0,
+						},
+					},
+				},
+			},
+			FieldSignificanceThreshold: {
+				Type:     schema.TypeFloat,
+				Optional: true,
+				Default:  0.0,
+			},
+			// "Outputs"
+			FieldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldSignificantHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// getComposite hashes values of all the parts as well as values of only the parts whose weight is at least the
+// significance threshold, both in the order the parts are listed
+func getComposite(d resourceGetter, m interface{}) map[string]interface{} {
+	threshold := d.Get(FieldSignificanceThreshold).(float64)
+
+	all := []interface{}{}
+	significant := []interface{}{}
+	for _, part := range d.Get(FieldParts).([]interface{}) {
+		part := part.(map[string]interface{})
+		all = append(all, part[FieldPartValue])
+		if part[FieldPartWeight].(float64) >= threshold {
+			significant = append(significant, part[FieldPartValue])
+		}
+	}
+	return map[string]interface{}{
+		FieldHash:            getSerializedHash(all, m),
+		FieldSignificantHash: getSerializedHash(significant, m),
+	}
+}
+
+func setComposite(d *schema.ResourceData, m interface{}) {
+	for key, value := range getComposite(d, m) {
+		d.Set(key, value)
+	}
+}
+
+func createComposite(d *schema.ResourceData, m interface{}) error {
+	d.SetId(uuid.NewV4().String())
+	setComposite(d, m)
+	return nil
+}
+
+func readComposite(d *schema.ResourceData, m interface{}) error {
+	setComposite(d, m)
+	return nil
+}
+
+func updateComposite(d *schema.ResourceData, m interface{}) error {
+	setComposite(d, m)
+	return nil
+}
+
+func diffComposite(d *schema.ResourceDiff, m interface{}) error {
+	if !isCompositeKnown(d) {
+		for _, key := range compositeOutputs {
+			d.SetNewComputed(key)
+		}
+		return nil
+	}
+
+	changed := false
+	for _, key := range compositeFields {
+		changed = changed || d.HasChange(key)
+	}
+	if changed || d.Id() == "" {
+		for key, value := range getComposite(d, m) {
+			d.SetNew(key, value)
+		}
+	}
+	return nil
+}
+
+// isCompositeKnown checks whether all the inputs are known, reading a list whose value is unknown as a whole panics so
+// its count is checked first
+func isCompositeKnown(d *schema.ResourceDiff) bool {
+	if !d.NewValueKnown(FieldParts+".#") || !d.NewValueKnown(FieldSignificanceThreshold) {
+		return false
+	}
+	for i := range d.Get(FieldParts).([]interface{}) {
+		for _, key := range []string{FieldPartValue, FieldPartWeight} {
+			if !d.NewValueKnown(fmt.Sprintf("%s.%d.%s", FieldParts, i, key)) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateComposite = `
+resource "stateful_composite" "object" {
+  parts {
+    value  = "image:%s"
+    weight = 10
+  }
+  parts {
+    value  = "comment:%s"
+    weight = 1
+  }
+  significance_threshold = 5
+}
+`
+
+func TestStatefulComposite(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateComposite, "v1", "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_composite.object", "hash", strPtr(getSHA256([]string{"image:v1", "comment:foo"}))),
+					testResourceAttrEquals("stateful_composite.object", "significant_hash", strPtr(getSHA256([]string{"image:v1"}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateComposite, "v1", "bar"), // low-weight part changes
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_composite.object", "hash", strPtr(getSHA256([]string{"image:v1", "comment:bar"}))),
+					testResourceAttrEquals("stateful_composite.object", "significant_hash", strPtr(getSHA256([]string{"image:v1"}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateComposite, "v2", "bar"), // significant part changes
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_composite.object", "significant_hash", strPtr(getSHA256([]string{"image:v2"}))),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,92 @@
+package stateful
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/satori/go.uuid"
+)
+
+const FieldProgram = "program"
+const FieldMaxOutputBytes = "max_output_bytes"
+const FieldOutput = "output"
+
+func resourceStatefulExec() *schema.Resource {
+	return &schema.Resource{
+		Create: createExec,
+		Read:   readExec,
+		Update: updateExec,
+		Delete: deleteResource,
+
+		CustomizeDiff: diffExec,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldProgram: {
+				Type:     schema.TypeList,
+				Required: true,
+				MinItems: 1,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldMaxOutputBytes: {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1048576,
+				ValidateFunc: validatePositive,
+			},
+			// "Outputs"
+			FieldOutput: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// setExecOutput runs the program and fingerprints its output (sans trailing newline) the same way as desired value of
+// stateful_string, it's rerun upon every refresh so that changes of the output are picked up
+func setExecOutput(d *schema.ResourceData, m interface{}) error {
+	program := d.Get(FieldProgram).([]interface{})
+	args := make([]string, len(program))
+	for i, arg := range program {
+		args[i] = arg.(string)
+	}
+
+	output, err := runCommandWithLimit(args, d.Get(FieldMaxOutputBytes).(int))
+	if err != nil {
+		return err
+	}
+	value := strings.TrimSuffix(string(output), "\n")
+	d.Set(FieldOutput, value)
+	d.Set(FieldHash, getSerializedHash(value, m))
+	return nil
+}
+
+func createExec(d *schema.ResourceData, m interface{}) error {
+	// The id is only assigned once the program succeeds so that a failure doesn't leave a resource behind
+	if err := setExecOutput(d, m); err != nil {
+		return err
+	}
+	d.SetId(uuid.NewV4().String())
+	return nil
+}
+
+func readExec(d *schema.ResourceData, m interface{}) error {
+	return setExecOutput(d, m)
+}
+
+func updateExec(d *schema.ResourceData, m interface{}) error {
+	return setExecOutput(d, m)
+}
+
+func diffExec(d *schema.ResourceDiff, m interface{}) error {
+	if d.HasChange(FieldProgram) || d.HasChange(FieldMaxOutputBytes) {
+		d.SetNewComputed(FieldOutput)
+		d.SetNewComputed(FieldHash)
+	}
+	return nil
+}
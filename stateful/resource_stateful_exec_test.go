@@ -0,0 +1,68 @@
+package stateful
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateExec = `
+resource "stateful_exec" "object" {
+  program          = %s
+  max_output_bytes = %d
+}
+`
+
+func TestStatefulExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stateful")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "output")
+
+	write := func(content string) func() {
+		return func() {
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	program := fmt.Sprintf(`["cat", "%s"]`, path)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateExec, `["sh", "-c", "echo oops >&2; exit 1"]`, 1024),
+				ExpectError: regexp.MustCompile(`command \["sh" "-c" "echo oops >&2; exit 1"\] failed: exit status 1: oops`),
+			},
+			{
+				PreConfig:   write("foo\n"),
+				Config:      fmt.Sprintf(templateExec, program, 2),
+				ExpectError: regexp.MustCompile("output exceeds 2 bytes"),
+			},
+			{
+				Config: fmt.Sprintf(templateExec, program, 1024),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_exec.object", "output", strPtr("foo")),
+					testResourceAttrEquals("stateful_exec.object", "hash", strPtr(getSHA256("foo"))),
+				),
+			},
+			{
+				PreConfig: write("bar\n"), // output changes between plans
+				Config:    fmt.Sprintf(templateExec, program, 1024),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_exec.object", "output", strPtr("bar")),
+					testResourceAttrEquals("stateful_exec.object", "hash", strPtr(getSHA256("bar"))),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package stateful
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/satori/go.uuid"
+)
+
+const FieldPath = "path"
+
+func resourceStatefulFile() *schema.Resource {
+	return &schema.Resource{
+		Create: createFile,
+		Read:   readFile,
+		Update: updateFile,
+		Delete: deleteResource,
+
+		CustomizeDiff: diffFile,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldPath: {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			// "Outputs"
+			FieldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// setFileHash fingerprints the current content of the file, it's rehashed upon every refresh as files change outside
+// of Terraform
+func setFileHash(d *schema.ResourceData, m interface{}) error {
+	content, err := ioutil.ReadFile(d.Get(FieldPath).(string))
+	if err != nil {
+		return fmt.Errorf("'%s' cannot be read: %s", FieldPath, err)
+	}
+	d.Set(FieldHash, getConfiguredDigest(content, m))
+	return nil
+}
+
+func createFile(d *schema.ResourceData, m interface{}) error {
+	// The id is only assigned once the file is read so that a missing file doesn't leave a resource behind
+	if err := setFileHash(d, m); err != nil {
+		return err
+	}
+	d.SetId(uuid.NewV4().String())
+	return nil
+}
+
+func readFile(d *schema.ResourceData, m interface{}) error {
+	return setFileHash(d, m)
+}
+
+func updateFile(d *schema.ResourceData, m interface{}) error {
+	return setFileHash(d, m)
+}
+
+func diffFile(d *schema.ResourceDiff, m interface{}) error {
+	if d.HasChange(FieldPath) {
+		d.SetNewComputed(FieldHash)
+	}
+	return nil
+}
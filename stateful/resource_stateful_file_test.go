@@ -0,0 +1,56 @@
+package stateful
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateFile = `
+resource "stateful_file" "object" {
+  path = "%s"
+}
+`
+
+func TestStatefulFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stateful")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+
+	write := func(content string) func() {
+		return func() {
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateFile, filepath.Join(dir, "missing")),
+				ExpectError: regexp.MustCompile("'path' cannot be read: open .+: no such file or directory"),
+			},
+			{
+				PreConfig: write("foo"),
+				Config:    fmt.Sprintf(templateFile, path),
+				Check:     testResourceAttrEquals("stateful_file.object", "hash", strPtr(getDigest([]byte("foo")))),
+			},
+			{
+				PreConfig: write("bar"), // changed outside of Terraform
+				Config:    fmt.Sprintf(templateFile, path),
+				Check:     testResourceAttrEquals("stateful_file.object", "hash", strPtr(getDigest([]byte("bar")))),
+			},
+		},
+	})
+}
@@ -0,0 +1,127 @@
+package stateful
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/satori/go.uuid"
+)
+
+const FieldProducedAt = "produced_at"
+const FieldObservedAt = "observed_at"
+const FieldMaxLag = "max_lag"
+
+const FieldStale = "stale"
+const FieldLag = "lag"
+
+// freshnessFields lists all inputs of the freshness check so that outputs are recomputed whenever any of them changes
+var freshnessFields = []string{FieldProducedAt, FieldObservedAt, FieldMaxLag}
+
+// freshnessOutputs lists all attributes computed from the inputs of the freshness check
+var freshnessOutputs = []string{FieldStale, FieldLag}
+
+func resourceStatefulFreshness() *schema.Resource {
+	return &schema.Resource{
+		Create: createFreshness,
+		Read:   readFreshness,
+		Update: updateFreshness,
+		Delete: deleteResource,
+
+		CustomizeDiff: diffFreshness,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldProducedAt: {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateTimestamp,
+			},
+			FieldObservedAt: {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateTimestamp,
+			},
+			FieldMaxLag: {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateDuration,
+			},
+			// "Outputs"
+			FieldStale: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldLag: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// getFreshness compares the time a value was observed at against the time it was produced at: it's stale when it was
+// never observed, observed before it was produced or the lag between the two exceeds the maximum one
+func getFreshness(d resourceGetter) map[string]interface{} {
+	producedAt, _ := time.Parse(time.RFC3339, d.Get(FieldProducedAt).(string))
+	maxLag, _ := time.ParseDuration(d.Get(FieldMaxLag).(string))
+
+	observedAt, err := time.Parse(time.RFC3339, d.Get(FieldObservedAt).(string))
+	if err != nil {
+		return map[string]interface{}{FieldStale: true, FieldLag: ""}
+	}
+	lag := observedAt.Sub(producedAt)
+	return map[string]interface{}{
+		FieldStale: lag < 0 || lag > maxLag,
+		FieldLag:   formatDuration(lag),
+	}
+}
+
+func validateTimestamp(v interface{}, k string) ([]string, []error) {
+	if _, err := time.Parse(time.RFC3339, v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("'%s' must be an RFC 3339 timestamp, e.g. '2006-01-02T15:04:05Z': %s", k, err)}
+	}
+	return nil, nil
+}
+
+func setFreshness(d *schema.ResourceData) {
+	for key, value := range getFreshness(d) {
+		d.Set(key, value)
+	}
+}
+
+func createFreshness(d *schema.ResourceData, m interface{}) error {
+	d.SetId(uuid.NewV4().String())
+	setFreshness(d)
+	return nil
+}
+
+func readFreshness(d *schema.ResourceData, m interface{}) error {
+	setFreshness(d)
+	return nil
+}
+
+func updateFreshness(d *schema.ResourceData, m interface{}) error {
+	setFreshness(d)
+	return nil
+}
+
+func diffFreshness(d *schema.ResourceDiff, m interface{}) error {
+	changed := false
+	for _, key := range freshnessFields {
+		if !d.NewValueKnown(key) {
+			for _, key := range freshnessOutputs {
+				d.SetNewComputed(key)
+			}
+			return nil
+		}
+		changed = changed || d.HasChange(key)
+	}
+
+	if changed || d.Id() == "" {
+		for key, value := range getFreshness(d) {
+			d.SetNew(key, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateFreshness = `
+resource "stateful_freshness" "object" {
+  produced_at = "2019-01-01T00:00:00Z"
+  observed_at = "%s"
+  max_lag     = "1h"
+}
+`
+
+func TestStatefulFreshness(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateFreshness, "2019-01-01T00:30:00Z"), // within the lag
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_freshness.object", "stale", strPtr("false")),
+					testResourceAttrEquals("stateful_freshness.object", "lag", strPtr("30m")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateFreshness, "2019-01-01T01:30:00Z"), // exceeds the lag
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_freshness.object", "stale", strPtr("true")),
+					testResourceAttrEquals("stateful_freshness.object", "lag", strPtr("1h30m")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateFreshness, "2018-12-31T23:00:00Z"), // observed before produced
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_freshness.object", "stale", strPtr("true")),
+					testResourceAttrEquals("stateful_freshness.object", "lag", strPtr("-1h")),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,86 @@
+package stateful
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/satori/go.uuid"
+)
+
+func resourceStatefulStringSet() *schema.Resource {
+	return &schema.Resource{
+		Create: createStringSet,
+		Read:   readStringSet,
+		Update: updateStringSet,
+		Delete: deleteResource,
+
+		CustomizeDiff: diffStringSet,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldDesired: {
+				Type:     schema.TypeMap,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			// "Outputs"
+			FieldHashes: {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// getStringSetHashes fingerprints every named value the same way as desired value of stateful_string and rolls them
+// up into a single hash of their sorted concatenation
+func getStringSetHashes(d resourceGetter, m interface{}) (map[string]interface{}, string) {
+	desired := d.Get(FieldDesired).(map[string]interface{})
+	hashes := make(map[string]interface{}, len(desired))
+	sorted := make([]string, 0, len(desired))
+	for name, value := range desired {
+		hash := getSerializedHash(value, m)
+		hashes[name] = hash
+		sorted = append(sorted, hash)
+	}
+	sort.Strings(sorted)
+	return hashes, getConfiguredDigest([]byte(strings.Join(sorted, "")), m)
+}
+
+func setStringSetHashes(d *schema.ResourceData, m interface{}) {
+	hashes, hash := getStringSetHashes(d, m)
+	d.Set(FieldHashes, hashes)
+	d.Set(FieldHash, hash)
+}
+
+func createStringSet(d *schema.ResourceData, m interface{}) error {
+	d.SetId(uuid.NewV4().String())
+	setStringSetHashes(d, m)
+	return nil
+}
+
+func readStringSet(d *schema.ResourceData, m interface{}) error {
+	setStringSetHashes(d, m)
+	return nil
+}
+
+func updateStringSet(d *schema.ResourceData, m interface{}) error {
+	setStringSetHashes(d, m)
+	return nil
+}
+
+func diffStringSet(d *schema.ResourceDiff, m interface{}) error {
+	// Reading a map whose value is unknown as a whole panics, so its count is checked instead
+	if !d.NewValueKnown(FieldDesired+".%") || d.HasChange(FieldDesired) {
+		// The hash is marked first as it drops the diff of hashes sharing the prefix
+		d.SetNewComputed(FieldHash)
+		d.SetNewComputed(FieldHashes)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package stateful
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateStringSet = `
+resource "stateful_string_set" "object" {
+  desired = {
+    %s
+  }
+}
+`
+
+func TestStatefulStringSet(t *testing.T) {
+	combined := func(values ...string) *string {
+		hashes := make([]string, len(values))
+		for i, value := range values {
+			hashes[i] = getSHA256(value)
+		}
+		sort.Strings(hashes)
+		return strPtr(getDigest([]byte(strings.Join(hashes, ""))))
+	}
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateStringSet, `a = "foo"
+    b = "bar"`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string_set.object", "hashes.a", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string_set.object", "hashes.b", strPtr(getSHA256("bar"))),
+					testResourceAttrEquals("stateful_string_set.object", "hash", combined("foo", "bar")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateStringSet, `a = "foo"
+    b = "baz"`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string_set.object", "hashes.a", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string_set.object", "hashes.b", strPtr(getSHA256("baz"))),
+					testResourceAttrEquals("stateful_string_set.object", "hash", combined("foo", "baz")),
+				),
+			},
+		},
+	})
+}
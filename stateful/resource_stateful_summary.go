@@ -0,0 +1,135 @@
+package stateful
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/terraform/config/hcl2shim"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/satori/go.uuid"
+)
+
+const FieldHashes = "hashes"
+const FieldDrifts = "drifts"
+
+const FieldDriftCount = "drift_count"
+const FieldAggregateHash = "aggregate_hash"
+
+// summaryFields lists all inputs of the summary so that outputs are recomputed whenever any of them changes
+var summaryFields = []string{FieldHashes, FieldDrifts}
+
+// summaryOutputs lists all attributes computed from the inputs of the summary
+var summaryOutputs = []string{FieldAnyDrift, FieldDriftCount, FieldAggregateHash}
+
+func resourceStatefulSummary() *schema.Resource {
+	return &schema.Resource{
+		Create: createSummary,
+		Read:   readSummary,
+		Update: updateSummary,
+		Delete: deleteResource,
+
+		CustomizeDiff: diffSummary,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldHashes: {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			FieldDrifts: {
+				// Maps of booleans cannot be validated while any of their values is unknown, so drift flags are
+				// accepted as strings that Terraform converts booleans to
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateBoolMap,
+			},
+			// "Outputs"
+			FieldAnyDrift: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			FieldDriftCount: {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			// Plain "hash" cannot be used as ResourceDiff drops diffs of all fields sharing the prefix, e.g. hashes
+			FieldAggregateHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// getSummary counts drifting members and combines their hashes into a single aggregate one
+func getSummary(d resourceGetter, m interface{}) map[string]interface{} {
+	driftCount := 0
+	for _, drift := range d.Get(FieldDrifts).(map[string]interface{}) {
+		if drifted, _ := strconv.ParseBool(drift.(string)); drifted {
+			driftCount++
+		}
+	}
+	return map[string]interface{}{
+		FieldAnyDrift:      driftCount > 0,
+		FieldDriftCount:    driftCount,
+		FieldAggregateHash: getSerializedHash(d.Get(FieldHashes), m),
+	}
+}
+
+func validateBoolMap(v interface{}, k string) ([]string, []error) {
+	var errs []error
+	for key, value := range v.(map[string]interface{}) {
+		if value == hcl2shim.UnknownVariableValue {
+			continue
+		}
+		if _, err := strconv.ParseBool(value.(string)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: value of '%s' must be a boolean, got '%s'", k, key, value))
+		}
+	}
+	return nil, errs
+}
+
+func setSummary(d *schema.ResourceData, m interface{}) {
+	for key, value := range getSummary(d, m) {
+		d.Set(key, value)
+	}
+}
+
+func createSummary(d *schema.ResourceData, m interface{}) error {
+	d.SetId(uuid.NewV4().String())
+	setSummary(d, m)
+	return nil
+}
+
+func readSummary(d *schema.ResourceData, m interface{}) error {
+	setSummary(d, m)
+	return nil
+}
+
+func updateSummary(d *schema.ResourceData, m interface{}) error {
+	setSummary(d, m)
+	return nil
+}
+
+func diffSummary(d *schema.ResourceDiff, m interface{}) error {
+	changed := false
+	for _, key := range summaryFields {
+		// Reading a map whose value is unknown as a whole panics, so its count is checked instead
+		if !d.NewValueKnown(key + ".%") {
+			for _, key := range summaryOutputs {
+				d.SetNewComputed(key)
+			}
+			return nil
+		}
+		changed = changed || d.HasChange(key)
+	}
+
+	if changed || d.Id() == "" {
+		for key, value := range getSummary(d, m) {
+			d.SetNew(key, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateSummary = `
+resource "stateful_string" "a" {
+  desired = "foo"
+  real    = "foo"
+}
+resource "stateful_string" "b" {
+  desired = "bar"
+  real    = "%s"
+}
+resource "stateful_summary" "fleet" {
+  hashes = {
+    a = stateful_string.a.hash
+    b = stateful_string.b.hash
+  }
+  drifts = {
+    a = stateful_string.a.drifted
+    b = stateful_string.b.drifted
+  }
+}
+`
+
+func TestStatefulSummary(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateSummary, "baz"), // one of the objects drifts
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_summary.fleet", "any_drift", strPtr("true")),
+					testResourceAttrEquals("stateful_summary.fleet", "drift_count", strPtr("1")),
+					testResourceAttrEquals("stateful_summary.fleet", "aggregate_hash", strPtr(getSHA256(map[string]string{
+						"a": getSHA256("foo"),
+						"b": getSHA256("bar"),
+					}))),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateSummary, "bar"), // all objects are in sync
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_summary.fleet", "any_drift", strPtr("false")),
+					testResourceAttrEquals("stateful_summary.fleet", "drift_count", strPtr("0")),
+				),
+			},
+		},
+	})
+}
+
+func TestStatefulSummaryHashAlgorithm(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+provider "stateful" {
+  hash_algorithm = "md5"
+}
+resource "stateful_summary" "fleet" {
+  hashes = {
+    a = "foo"
+  }
+}
+`,
+				// md5 of `{"a":"foo"}`
+				Check: testResourceAttrEquals("stateful_summary.fleet", "aggregate_hash", strPtr("abcb0f450655a769d9504fa07a3f9e04")),
+			},
+		},
+	})
+}
@@ -3,8 +3,19 @@ package stateful
 import (
 	"testing"
 
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/terraform"
 )
@@ -38,6 +49,8 @@ func TestStatefulString(t *testing.T) {
 				Check: resource.ComposeTestCheckFunc(
 					// hash should be derived from desired value
 					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+					// id is a random UUID by default
+					testResourceAttrEquals("stateful_string.object", "id_source", strPtr(IdSourceRandomV4)),
 					// Extract null resource's ID to track its recreation
 					func(state *terraform.State) error {
 						*nullResourceId = getResourceAttr(state, "null_resource.updates", "id")
@@ -61,6 +74,8 @@ func TestStatefulString(t *testing.T) {
 				Check: resource.ComposeTestCheckFunc(
 					// hash should be derived from desired value
 					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("bar"))),
+					// desired value hash was derived from is captured
+					testResourceAttrEquals("stateful_string.object", "diffbase", strPtr("bar")),
 					// null_resource should be recreated
 					testResourceAttrDoesNotEqual("null_resource.updates", "id", nullResourceId),
 				),
@@ -79,10 +94,2426 @@ func TestStatefulString(t *testing.T) {
 	})
 }
 
+const templateNumber = `
+resource "stateful_number" "object" {
+  desired   = %s
+  real      = %s
+  tolerance = 0.5
+}
+`
+
+func TestStatefulNumber(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateNumber, "1.00", "1.4"), // within tolerance
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_number.object", "hash", strPtr(getSHA256(1.0))),
+					testResourceAttrEquals("stateful_number.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateNumber, "1.0", "1.6"), // outside of tolerance
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_number.object", "hash", strPtr(getSHA256(1.0))),
+					testResourceAttrEquals("stateful_number.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateNumberCompare = `
+resource "stateful_number" "object" {
+  desired = 10
+  %s
+  compare = "%s"
+}
+`
+
+func TestStatefulNumberCompare(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateNumberCompare, "", "gt"),
+				ExpectError: regexp.MustCompile("'compare' must be one of 'eq', 'gte' or 'lte', got 'gt'"),
+			},
+			{
+				Config:             fmt.Sprintf(templateNumberCompare, "real = 12", "gte"), // exceeds desired
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_number.object", "hash", strPtr(getSHA256(10.0))),
+					testResourceAttrEquals("stateful_number.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateNumberCompare, "real = 8", "gte"), // falls below desired
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_number.object", "drifted", strPtr("true")),
+			},
+			{
+				Config:             fmt.Sprintf(templateNumberCompare, "real = 8", "lte"),
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_number.object", "drifted", strPtr("false")),
+			},
+			{
+				Config:             fmt.Sprintf(templateNumberCompare, "", "gte"), // unset
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_number.object", "drifted", strPtr("false")),
+			},
+		},
+	})
+}
+
+const templateBool = `
+resource "stateful_bool" "object" {
+  desired = true
+  %s
+}
+`
+
+func TestStatefulBool(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateBool, "real = true"),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_bool.object", "hash", strPtr(getSHA256(true))),
+					testResourceAttrEquals("stateful_bool.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateBool, "real = false"), // explicitly set to the zero value
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_bool.object", "drifted", strPtr("true")),
+			},
+			{
+				Config:             fmt.Sprintf(templateBool, ""), // unset
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_bool.object", "drifted", strPtr("false")),
+			},
+		},
+	})
+}
+
+const templateJson = `
+resource "stateful_json" "object" {
+  desired = %q
+  real    = %q
+}
+`
+
+func TestStatefulJson(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateJson, `{"foo": }`, `{}`),
+				ExpectError: regexp.MustCompile(`'desired' must be valid JSON: invalid character '}' looking for beginning of value \(offset 9\)`),
+			},
+			{
+				Config:             fmt.Sprintf(templateJson, `{"foo": 1, "bar": [1, 2]}`, `{ "bar":[1,2],"foo":1 }`),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_json.object", "hash", strPtr(getSHA256(`{"bar":[1,2],"foo":1}`))),
+					testResourceAttrEquals("stateful_json.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateJson, "{\n  \"bar\": [1, 2],\n  \"foo\": 1\n}", `{"foo":1,"bar":[2,1]}`),
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					// Formatting alone doesn't change the hash
+					testResourceAttrEquals("stateful_json.object", "hash", strPtr(getSHA256(`{"bar":[1,2],"foo":1}`))),
+					testResourceAttrEquals("stateful_json.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+func TestStatefulJsonLargeNumbers(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateJson, `{"id": 10000000000000001}`, `{"id":10000000000000001}`),
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_json.object", "hash", strPtr(getSHA256(`{"id":10000000000000001}`))),
+			},
+			{
+				Config:             fmt.Sprintf(templateJson, `{"id": 10000000000000001}`, `{"id":10000000000000001}`), // re-apply
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_json.object", "hash", strPtr(getSHA256(`{"id":10000000000000001}`))),
+			},
+			{
+				// Both collapse into the same float64
+				Config:             fmt.Sprintf(templateJson, `{"id": 10000000000000001}`, `{"id":10000000000000000}`),
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_json.object", "drifted", strPtr("true")),
+			},
+		},
+	})
+}
+
+const templateTypedMap = `
+resource "stateful_typed_map" "object" {
+  desired = %q
+  real    = %q
+}
+`
+
+func TestStatefulTypedMap(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateTypedMap, `[1, 2]`, `{}`),
+				ExpectError: regexp.MustCompile(`'desired' must be a JSON object, got '\[1, 2\]'`),
+			},
+			{
+				Config:             fmt.Sprintf(templateTypedMap, `{"count": 1, "nested": {"on": true, "tags": ["a"]}}`, `{"nested": {"tags": ["a"], "on": true}, "count": 1.0}`),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_typed_map.object", "hash", strPtr(getSHA256(`{"count":1,"nested":{"on":true,"tags":["a"]}}`))),
+					testResourceAttrEquals("stateful_typed_map.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateTypedMap, `{"count": 1}`, `{"count": "1"}`), // types are preserved
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_typed_map.object", "drifted", strPtr("true")),
+			},
+		},
+	})
+}
+
+const templateList = `
+resource "stateful_list" "object" {
+  desired = [%s]
+  real    = [%s]
+}
+`
+
+func TestStatefulList(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateList, `"a", "b"`, `"a", "b"`), // identical list clears real
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_list.object", "hash", strPtr(getSHA256([]string{"a", "b"}))),
+					testResourceAttrEquals("stateful_list.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateList, `"b", "a"`, `"b", "a"`), // reordering changes hash
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_list.object", "hash", strPtr(getSHA256([]string{"b", "a"}))),
+					testResourceAttrEquals("stateful_list.object", "diffbase.0", strPtr("b")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateList, `"b", "a"`, `"a", "b"`), // real differs in order only
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_list.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateSet = `
+resource "stateful_set" "object" {
+  desired    = [%s]
+  real       = [%s]
+  transforms = ["lower"]
+}
+`
+
+func TestStatefulSet(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateSet, `"c", "b", "a"`, `"a", "b", "c"`), // order is irrelevant
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_set.object", "hash", strPtr(getSHA256([]string{"a", "b", "c"}))),
+					testResourceAttrEquals("stateful_set.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateSet, `"A", "a", "b"`, `"b", "a"`), // duplicate after normalization
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_set.object", "hash", strPtr(getSHA256([]string{"a", "b"}))),
+					testResourceAttrEquals("stateful_set.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateSet, `"a", "b"`, `"a", "c"`), // members differ
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_set.object", "drifted", strPtr("true")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateSet, "", ""), // empty set
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_set.object", "hash", strPtr(getSHA256([]string{}))),
+					testResourceAttrEquals("stateful_set.object", "is_empty", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateReals = `
+resource "stateful_string" "object" {
+  desired = "%s"
+  sources = {
+    primary   = "%s"
+    secondary = "%s"
+  }
+}
+`
+
+func TestStatefulStringReals(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateReals, "foo", "foo", "bar"), // one source drifts
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.primary", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.secondary", strPtr("true")),
+					testResourceAttrEquals("stateful_string.object", "any_drift", strPtr("true")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateReals, "foo", "foo", "foo"), // all sources are in sync
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.primary", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.secondary", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "any_drift", strPtr("false")),
+				),
+			},
+		},
+	})
+}
+
+const templateSourcesDrifted = `
+resource "stateful_string" "object" {
+  desired = "foo"
+  real    = "bar"
+  sources = {
+    primary = "%s"
+  }
+}
+`
+
+func TestStatefulStringSourcesDrifted(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateSourcesDrifted, "bar"),
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.primary", strPtr("true")),
+				),
+			},
+			{
+				// Sources change along with real being marked as computed upon drift
+				Config:             fmt.Sprintf(templateSourcesDrifted, "foo"),
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+					testResourceAttrEquals("stateful_string.object", "sources.primary", strPtr("foo")),
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.primary", strPtr("false")),
+				),
+			},
+		},
+	})
+}
+
+const templateSourcesQuerystring = `
+resource "stateful_string" "object" {
+  desired           = "a=1&b=2"
+  querystring_value = true
+  sources = {
+    primary   = "b=2&a=1"
+    secondary = "a=1&b=3"
+  }
+}
+`
+
+func TestStatefulStringSourcesQuerystring(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             templateSourcesQuerystring, // sources are normalized the same way as real
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.primary", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "per_source_drift.secondary", strPtr("true")),
+					testResourceAttrEquals("stateful_string.object", "any_drift", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateOmitEmpty = `
+resource "stateful_map" "object" {
+  desired    = {
+    a = "1"
+    b = ""
+  }
+  real       = {
+    a = "1"
+  }
+  omit_empty = true
+}
+`
+
+func TestStatefulMapOmitEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             templateOmitEmpty,
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					// empty values are not part of the fingerprint
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256(map[string]string{"a": "1"}))),
+				),
+			},
+		},
+	})
+}
+
+func TestStatefulStringDiff(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             getConfig("foo", "bar"), // drift
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+					testResourceAttrEquals("stateful_string.object", "diff", strPtr("--- desired\n+++ real\n@@ -1,1 +1,1 @@\n-foo\n+bar\n")),
+				),
+			},
+			{
+				Config:             getConfig("foo", "foo"), // in sync
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "diff", strPtr("")),
+				),
+			},
+		},
+	})
+}
+
+const templateMapDiff = `
+resource "stateful_map" "object" {
+  desired = %s
+  real    = %s
+  %s
+}
+`
+
+func TestStatefulMapDiff(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateMapDiff, `{a = "1", b = "2", c = "3"}`, `{b = "2", c = "4", d = "5"}`, ""), // drift
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+					testResourceAttrEquals("stateful_map.object", "diff.%", strPtr("3")),
+					testResourceAttrEquals("stateful_map.object", "diff.a", strPtr("missing")),
+					testResourceAttrEquals("stateful_map.object", "diff.c", strPtr("changed")),
+					testResourceAttrEquals("stateful_map.object", "diff.d", strPtr("extra")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateMapDiff, `{a = "1"}`, `{a = "1"}`, ""), // in sync
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_map.object", "diff.%", strPtr("0")),
+				),
+			},
+			{
+				// Ignored keys are not reported even though their values differ
+				Config:             fmt.Sprintf(templateMapDiff, `{a = "1", etag = "1"}`, `{a = "2", etag = "2"}`, `ignore_keys = ["etag"]`),
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+					testResourceAttrEquals("stateful_map.object", "diff.%", strPtr("1")),
+					testResourceAttrEquals("stateful_map.object", "diff.a", strPtr("changed")),
+				),
+			},
+		},
+	})
+}
+
+const templateLength = `
+resource "stateful_list" "list" {
+  desired = %s
+}
+resource "stateful_set" "set" {
+  desired = %s
+}
+resource "stateful_map" "map" {
+  desired = %s
+}
+`
+
+func TestStatefulLength(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateLength, `["a", "b", "a"]`, `["a", "b", "a"]`, `{a = "1", b = "2"}`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_list.list", "length", strPtr("3")),
+					testResourceAttrEquals("stateful_set.set", "length", strPtr("2")),
+					testResourceAttrEquals("stateful_map.map", "length", strPtr("2")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateLength, `[]`, `[]`, `{}`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_list.list", "length", strPtr("0")),
+					testResourceAttrEquals("stateful_set.set", "length", strPtr("0")),
+					testResourceAttrEquals("stateful_map.map", "length", strPtr("0")),
+				),
+			},
+		},
+	})
+}
+
+const templateQuerystring = `
+resource "stateful_string" "object" {
+  desired           = "%s"
+  real              = "%s"
+  querystring_value = true
+}
+`
+
+func TestStatefulStringQuerystring(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateQuerystring, "a=1&b=2", "b=2&a=1"), // reordered parameters
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					// hash should be derived from the canonical form
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("a=1&b=2"))),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateQuerystring, "b=2&a=1", "a=1&b=2"), // same parameters
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					// hash should not change
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("a=1&b=2"))),
+				),
+			},
+		},
+	})
+}
+
+const templateNonce = `
+resource "stateful_string" "object" {
+  desired = "foo"
+  nonce   = %d
+}
+`
+
+func TestStatefulStringNonce(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateNonce, 0), // nonce is not set
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateNonce, 1), // nonce bumped
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(map[string]interface{}{"desired": "foo", "nonce": 1}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateNonce, 2), // nonce bumped again
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(map[string]interface{}{"desired": "foo", "nonce": 2}))),
+				),
+			},
+		},
+	})
+}
+
+const templateRealCommand = `
+resource "stateful_string" "object" {
+  desired         = "foo"
+  observe_command = ["echo", "%s"]
+}
+`
+
+func TestStatefulStringRealCommand(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateRealCommand, "foo"), // in sync
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+			},
+			{
+				Config:             fmt.Sprintf(templateRealCommand, "bar"), // drift
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestStatefulStringRealCommandDrifted(t *testing.T) {
+	for resourceType, c := range map[string]struct {
+		desired  map[string]string
+		drifting string
+		matching string
+	}{
+		"stateful_string": {map[string]string{FieldDesired: "foo"}, "bar", "foo"},
+		"stateful_map":    {map[string]string{FieldDesired + ".%": "1", FieldDesired + ".a": "1"}, `{"a":"2"}`, `{"a":"1"}`},
+		"stateful_list":   {map[string]string{FieldDesired + ".#": "1", FieldDesired + ".0": "a"}, `["b"]`, `["a"]`},
+	} {
+		attributes := map[string]string{
+			FieldObserveCommand + ".#": "2",
+			FieldObserveCommand + ".0": "echo",
+			FieldObserveCommand + ".1": c.drifting,
+		}
+		for key, value := range c.desired {
+			attributes[key] = value
+		}
+		d := statefulProvider.ResourcesMap[resourceType].Data(&terraform.InstanceState{ID: "id", Attributes: attributes})
+		if err := readResource(d, newConfig()); err != nil {
+			t.Fatal(err)
+		}
+		if !d.Get(FieldDrifted).(bool) {
+			t.Errorf("%s should be drifted once the real value reported upon refresh diverges from the desired one",
+				resourceType)
+		}
+
+		d.Set(FieldObserveCommand, []string{"echo", c.matching})
+		if err := readResource(d, newConfig()); err != nil {
+			t.Fatal(err)
+		}
+		if d.Get(FieldDrifted).(bool) {
+			t.Errorf("%s should not be drifted once the real value reported upon refresh matches the desired one",
+				resourceType)
+		}
+	}
+}
+
+const templatePublishCommand = `
+resource "stateful_string" "object" {
+  desired         = "%s"
+  publish_command = ["sh", "-c", "read hash; echo \"$1 $hash\" >> %s", "publish"]
+}
+`
+
+func TestStatefulStringPublishCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stateful")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	published := filepath.Join(dir, "published")
+
+	testPublished := func(hashes ...string) resource.TestCheckFunc {
+		return func(state *terraform.State) error {
+			content, err := ioutil.ReadFile(published)
+			if err != nil {
+				return err
+			}
+			var expected string
+			for _, hash := range hashes {
+				// The hash is passed both as an argument and via stdin
+				expected += hash + " " + hash + "\n"
+			}
+			if string(content) != expected {
+				return fmt.Errorf("published hashes '%s' do not match expected '%s'", content, expected)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templatePublishCommand, "foo", published), // create
+				Check:  testPublished(getSHA256("foo")),
+			},
+			{
+				Config: fmt.Sprintf(templatePublishCommand, "bar", published), // update
+				Check:  testPublished(getSHA256("foo"), getSHA256("bar")),
+			},
+		},
+	})
+}
+
+const templatePublishCommandFailure = `
+resource "stateful_string" "object" {
+  desired         = "foo"
+  publish_command = ["sh", "-c", "echo unavailable >&2; exit 1"]
+}
+`
+
+func TestStatefulStringPublishCommandFailure(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      templatePublishCommandFailure,
+				ExpectError: regexp.MustCompile("unavailable"),
+			},
+		},
+	})
+}
+
+const templateGroupSize = `
+resource "stateful_string" "object" {
+  desired    = "foo"
+  group_size = %d
+}
+`
+
+func TestStatefulStringGroupSize(t *testing.T) {
+	hash := getSHA256("foo")
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateGroupSize, 16),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(hash)),
+					testResourceAttrEquals("stateful_string.object", "hash_grouped", strPtr(hash[0:16]+"-"+hash[16:32]+"-"+hash[32:48]+"-"+hash[48:64])),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateGroupSize, 30), // last group is shorter
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(hash)),
+					testResourceAttrEquals("stateful_string.object", "hash_grouped", strPtr(hash[0:30]+"-"+hash[30:60]+"-"+hash[60:64])),
+				),
+			},
+		},
+	})
+}
+
+const templateAllowedHashes = `
+resource "stateful_string" "object" {
+  desired          = "foo"
+  allowed_hashes   = ["%s"]
+  require_approved = %t
+}
+`
+
+func TestStatefulStringAllowedHashes(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateAllowedHashes, getSHA256("foo"), false), // matching hash
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "approved", strPtr("true")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateAllowedHashes, getSHA256("bar"), false), // non-matching hash
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "approved", strPtr("false")),
+				),
+			},
+			{
+				Config:      fmt.Sprintf(templateAllowedHashes, getSHA256("bar"), true), // non-matching hash in strict mode
+				ExpectError: regexp.MustCompile("is not among 'allowed_hashes'"),
+			},
+		},
+	})
+}
+
+const templateKeyModes = `
+resource "stateful_map" "object" {
+  desired   = {
+    size = "%s"
+    name = "%s"
+  }
+  real      = {
+    size = "%s"
+    name = "%s"
+  }
+  key_modes = {
+    size = "numeric"
+    name = "ci"
+  }
+}
+`
+
+func TestStatefulMapKeyModes(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateKeyModes, "1", "Foo", "1.0", "FOO"), // equal under key modes
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateKeyModes, "1", "Foo", "2", "FOO"), // numeric value differs
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateKeyModes, "1", "Foo", "1", "Bar"), // case-insensitive value differs
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateIsEmpty = `
+resource "stateful_string" "string" {
+  desired = "%s"
+}
+resource "stateful_map" "map" {
+  desired = {%s}
+}
+resource "stateful_list" "list" {
+  desired = [%s]
+}
+resource "stateful_set" "set" {
+  desired = [%s]
+}
+resource "stateful_number" "number" {
+  desired = %s
+}
+resource "stateful_bool" "bool" {
+  desired = %s
+}
+`
+
+func TestStatefulIsEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateIsEmpty, "", "", "", "", "0", "false"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.string", "is_empty", strPtr("true")),
+					testResourceAttrEquals("stateful_map.map", "is_empty", strPtr("true")),
+					testResourceAttrEquals("stateful_list.list", "is_empty", strPtr("true")),
+					testResourceAttrEquals("stateful_set.set", "is_empty", strPtr("true")),
+					testResourceAttrEquals("stateful_number.number", "is_empty", strPtr("true")),
+					testResourceAttrEquals("stateful_bool.bool", "is_empty", strPtr("true")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateIsEmpty, "foo", `a = ""`, `""`, `""`, "0.5", "true"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.string", "is_empty", strPtr("false")),
+					testResourceAttrEquals("stateful_map.map", "is_empty", strPtr("false")),
+					testResourceAttrEquals("stateful_list.list", "is_empty", strPtr("false")),
+					testResourceAttrEquals("stateful_set.set", "is_empty", strPtr("false")),
+					testResourceAttrEquals("stateful_number.number", "is_empty", strPtr("false")),
+					testResourceAttrEquals("stateful_bool.bool", "is_empty", strPtr("false")),
+				),
+			},
+		},
+	})
+}
+
+const templateRealHash = `
+resource "stateful_string" "string" {
+  desired = "foo"
+  %s
+}
+resource "stateful_set" "set" {
+  desired = ["b", "a"]
+  real    = ["b", "a"]
+}
+`
+
+func TestStatefulRealHash(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateRealHash, ""),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.string", "real_hash", strPtr("")),
+					testResourceAttrEquals("stateful_set.set", "real_hash", strPtr(getSHA256([]string{"a", "b"}))),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateRealHash, `real = "bar"`),
+				ExpectNonEmptyPlan: true, // drift
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.string", "real_hash", strPtr(getSHA256("bar"))),
+					testResourceAttrEquals("stateful_string.string", "hash", strPtr(getSHA256("foo"))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateRealHash, `real = "foo"`), // converged
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.string", "real_hash", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.string", "is_real_set", strPtr("true")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateRealHash, ""), // real is unset
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.string", "real_hash", strPtr("")),
+					testResourceAttrEquals("stateful_string.string", "is_real_set", strPtr("false")),
+				),
+			},
+		},
+	})
+}
+
+const templateGeneration = `
+resource "stateful_string" "object" {
+  desired = "%s"
+  real    = "%s"
+}
+`
+
+func TestStatefulGeneration(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateGeneration, "foo", "foo"),
+				Check:  testResourceAttrEquals("stateful_string.object", "generation", strPtr("1")),
+			},
+			{
+				Config: fmt.Sprintf(templateGeneration, "foo", "foo"), // no-op apply
+				Check:  testResourceAttrEquals("stateful_string.object", "generation", strPtr("1")),
+			},
+			{
+				Config: fmt.Sprintf(templateGeneration, "bar", "bar"),
+				Check:  testResourceAttrEquals("stateful_string.object", "generation", strPtr("2")),
+			},
+			{
+				Config:             fmt.Sprintf(templateGeneration, "bar", "baz"), // drift doesn't change the hash
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_string.object", "generation", strPtr("2")),
+			},
+		},
+	})
+}
+
+const templateLastUpdated = `
+resource "stateful_string" "object" {
+  desired = "%s"
+  nonce   = %d
+}
+`
+
+func TestStatefulLastUpdated(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateLastUpdated, "foo", 0),
+				Check:  testResourceAttrEquals("stateful_string.object", "last_updated", strPtr("2019-06-01T00:00:00Z")),
+			},
+			{
+				PreConfig: func() { now = now.Add(time.Hour) },
+				Config:    fmt.Sprintf(templateLastUpdated, "foo", 0), // identical re-apply
+				Check:     testResourceAttrEquals("stateful_string.object", "last_updated", strPtr("2019-06-01T00:00:00Z")),
+			},
+			{
+				PreConfig: func() { now = now.Add(time.Hour) },
+				Config:    fmt.Sprintf(templateLastUpdated, "foo", 1), // hash changes but desired doesn't
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "last_updated", strPtr("2019-06-01T00:00:00Z")),
+					testResourceAttrEquals("stateful_string.object", "last_changed", strPtr("2019-06-01T02:00:00Z")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(time.Hour) },
+				Config:    fmt.Sprintf(templateLastUpdated, "bar", 1),
+				Check:     testResourceAttrEquals("stateful_string.object", "last_updated", strPtr("2019-06-01T03:00:00Z")),
+			},
+		},
+	})
+}
+
+const templateImport = `
+resource "stateful_string" "object" {
+  desired = "foo"
+}
+`
+
+func TestStatefulStringImport(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateImport,
+			},
+			{
+				ResourceName:      "stateful_string.object",
+				ImportState:       true,
+				ImportStateVerify: true,
+				// Only the id is imported while the desired value and attributes computed from it are derived from
+				// the configuration upon apply, as well as the ones that are only set once needed
+				ImportStateVerifyIgnore: []string{
+					FieldDesired, FieldHash, FieldGeneration, FieldLastChanged, FieldLastUpdated, FieldAge,
+					FieldDiffbase, FieldNormalizedValue, FieldSize, FieldLockToken, FieldStableId, FieldIdSource,
+					FieldRealHash, FieldIsRealSet, FieldDriftPending, FieldDriftFirstObserved, FieldRotationCount,
+				},
+				ImportStateCheck: func(states []*terraform.InstanceState) error {
+					if len(states) != 1 || states[0].Attributes[FieldHash] != "" {
+						return fmt.Errorf("expected a single imported resource without hash, got %v", states)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+// Import test steps don't persist the imported state, so applying the config to it is exercised separately
+func TestStatefulStringImportApply(t *testing.T) {
+	m := &Config{Serialization: SerializationJson, HashAlgorithm: HashAlgorithmSha256}
+	r := resourceStatefulString()
+
+	state, err := r.Refresh(&terraform.InstanceState{ID: "imported", Attributes: map[string]string{"id": "imported"}}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := config.NewRawConfig(map[string]interface{}{FieldDesired: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := r.Diff(state, terraform.NewResourceConfig(raw), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err = r.Apply(state, diff, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.ID != "imported" {
+		t.Errorf("id '%s' of the imported resource should be kept", state.ID)
+	}
+	if hash := state.Attributes[FieldHash]; hash != getSHA256("foo") {
+		t.Errorf("hash '%s' of the imported resource does not match expected '%s'", hash, getSHA256("foo"))
+	}
+}
+
+const templateIdInput = `
+resource "stateful_string" "object" {
+  desired  = "foo"
+  id_input = "%s"
+}
+`
+
+func TestStatefulStringIdInput(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateIdInput, "object-1"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "id", strPtr("object-1")),
+					testResourceAttrEquals("stateful_string.object", "id_source", strPtr("input")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateIdInput, "object-2"), // forces a new resource
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "id", strPtr("object-2")),
+					testResourceAttrEquals("stateful_string.object", "id_source", strPtr("input")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateIdInput, ""), // back to a random UUID
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrDoesNotEqual("stateful_string.object", "id", strPtr("object-2")),
+					testResourceAttrEquals("stateful_string.object", "id_source", strPtr("random-v4")),
+				),
+			},
+		},
+	})
+}
+
+const templateTruncateHash = `
+resource "stateful_string" "object" {
+  desired       = "foo"
+  truncate_hash = %d
+}
+`
+
+func TestStatefulStringTruncateHash(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateTruncateHash, 65),
+				ExpectError: regexp.MustCompile("'truncate_hash' must not exceed the length of 'sha256' digests \\(64\\), got 65"),
+			},
+			{
+				Config: fmt.Sprintf(templateTruncateHash, 8),
+				Check:  testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo")[:8])),
+			},
+			{
+				Config: fmt.Sprintf(templateTruncateHash, 64),
+				Check:  testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+			},
+		},
+	})
+}
+
+const templateDigests = `
+resource "stateful_string" "object" {
+  desired       = "%s"
+  truncate_hash = 8
+}
+`
+
+func TestStatefulStringDigests(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateDigests, "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo")[:8])),
+					// md5 and sha1 of `"foo"`, digests aren't truncated
+					testResourceAttrEquals("stateful_string.object", "hash_md5", strPtr("0dba520e335c06ba9240a978e9455878")),
+					testResourceAttrEquals("stateful_string.object", "hash_sha1", strPtr("d465e627f9946f2fa0d2dc0fc04e5385bc6cd46d")),
+					testResourceAttrEquals("stateful_string.object", "hash_sha256", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "size", strPtr("5")), // `"foo"`
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateDigests, "bar"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("bar")[:8])),
+					testResourceAttrEquals("stateful_string.object", "hash_sha256", strPtr(getSHA256("bar"))),
+				),
+			},
+		},
+	})
+}
+
+const templateIgnorePattern = `
+resource "stateful_string" "object" {
+  desired        = "build-20240101-ok"
+  real           = "%s"
+  ignore_pattern = "%s"
+}
+`
+
+func TestStatefulStringIgnorePattern(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateIgnorePattern, "build-20240101-ok", "[0-9"),
+				ExpectError: regexp.MustCompile("'ignore_pattern' must be a valid regular expression: error parsing regexp"),
+			},
+			{
+				Config:             fmt.Sprintf(templateIgnorePattern, "build-20250505-ok", "[0-9]{8}"),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("build--ok"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateIgnorePattern, "build-20250505-failed", "[0-9]{8}"),
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+const templateAcceptReal = `
+resource "stateful_string" "object" {
+  desired     = "foo"
+  real        = "bar"
+  accept_real = %t
+}
+`
+
+func TestStatefulStringAcceptReal(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateAcceptReal, false),
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "hash_source", strPtr("desired")),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateAcceptReal, true),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("bar"))),
+					testResourceAttrEquals("stateful_string.object", "hash_sha256", strPtr(getSHA256("bar"))),
+					testResourceAttrEquals("stateful_string.object", "hash_source", strPtr("real")),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "desired", strPtr("foo")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateAcceptReal, false), // compared against the original desired again
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "hash_source", strPtr("desired")),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateForceRecreate = `
+resource "stateful_string" "object" {
+  desired        = "foo"
+  force_recreate = {
+    secret = "%s"
+  }
+}
+`
+
+func TestStatefulStringForceRecreate(t *testing.T) {
+	var id string
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateForceRecreate, "v1"),
+				Check: func(state *terraform.State) error {
+					id = getResourceAttr(state, "stateful_string.object", "id")
+					return nil
+				},
+			},
+			{
+				Config: fmt.Sprintf(templateForceRecreate, "v2"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrDoesNotEqual("stateful_string.object", "id", &id),
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+				),
+			},
+		},
+	})
+}
+
+const templateKeepers = `
+resource "stateful_string" "object" {
+  desired = "foo"
+  keepers = {
+    secret = "%s"
+  }
+}
+`
+
+func TestStatefulStringKeepers(t *testing.T) {
+	var id string
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateKeepers, "v1"),
+				Check: resource.ComposeTestCheckFunc(
+					func(state *terraform.State) error {
+						id = getResourceAttr(state, "stateful_string.object", "id")
+						return nil
+					},
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(map[string]interface{}{
+						"desired": "foo", "keepers": map[string]interface{}{"secret": "v1"},
+					}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateKeepers, "v2"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "id", &id),
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(map[string]interface{}{
+						"desired": "foo", "keepers": map[string]interface{}{"secret": "v2"},
+					}))),
+				),
+			},
+		},
+	})
+}
+
+const templateHashAffixes = `
+resource "stateful_string" "object" {
+  desired = "foo"
+  prefix  = "%s"
+  suffix  = "%s"
+}
+`
+
+func TestStatefulHashAffixes(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateHashAffixes, "v1-", "-cfg"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr("v1-"+getSHA256("foo")+"-cfg")),
+					testResourceAttrEquals("stateful_string.object", "hash_raw", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "stable_id", strPtr(getSHA256("foo"))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateHashAffixes, "v2-", ""), // only the affixes change
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr("v2-"+getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "hash_raw", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "prefix", strPtr("v2-")),
+					testResourceAttrEquals("stateful_string.object", "stable_id", strPtr(getSHA256("foo"))),
+				),
+			},
+		},
+	})
+}
+
+const templateNormalizedValue = `
+resource "stateful_string" "string" {
+  desired              = "Foo  \r\nBar"
+  case_insensitive     = true
+  normalize_whitespace = true
+}
+resource "stateful_map" "map" {
+  desired = {
+    foo  = "bar"
+    etag = "1"
+  }
+  ignore_keys = ["etag"]
+}
+`
+
+func TestNormalizedValue(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateNormalizedValue,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.string", "normalized_value", strPtr("foo\nbar")),
+					testResourceAttrEquals("stateful_map.map", "normalized_value.%", strPtr("1")),
+					testResourceAttrEquals("stateful_map.map", "normalized_value.foo", strPtr("bar")),
+				),
+			},
+		},
+	})
+}
+
+const templateRealCandidates = `
+resource "stateful_string" "object" {
+  desired    = "enabled"
+  real       = "%s"
+  candidates = ["on", "yes"]
+}
+`
+
+func TestStatefulStringRealCandidates(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateRealCandidates, "on"), // a candidate other than desired
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("enabled"))),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateRealCandidates, "enabled"),
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+			},
+			{
+				Config:             fmt.Sprintf(templateRealCandidates, "off"),
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+const templateOldHash = `
+resource "stateful_string" "object" {
+  desired = "%s"
+}
+`
+
+func TestStatefulStringOldHash(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateOldHash, "foo"),
+				Check:  testResourceAttrEquals("stateful_string.object", "old_hash", strPtr("")),
+			},
+			{
+				Config: fmt.Sprintf(templateOldHash, "bar"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("bar"))),
+					testResourceAttrEquals("stateful_string.object", "old_hash", strPtr(getSHA256("foo"))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateOldHash, "baz"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("baz"))),
+					testResourceAttrEquals("stateful_string.object", "old_hash", strPtr(getSHA256("bar"))),
+				),
+			},
+		},
+	})
+}
+
+const templateStableId = `
+resource "stateful_string" "object" {
+  desired = "%s"
+}
+`
+
+func TestStatefulStringStableId(t *testing.T) {
+	var id string
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateStableId, "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "stable_id", strPtr(getSHA256("foo"))),
+					func(state *terraform.State) error {
+						id = getResourceAttr(state, "stateful_string.object", "id")
+						return nil
+					},
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateStableId, "bar"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "id", &id),
+					testResourceAttrEquals("stateful_string.object", "stable_id", strPtr(getSHA256("bar"))),
+				),
+			},
+		},
+	})
+}
+
+const templateEphemeral = `
+resource "stateful_string" "object" {
+  desired   = "foo"
+  ephemeral = true
+}
+`
+
+func TestStatefulStringEphemeral(t *testing.T) {
+	var hash string
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             templateEphemeral,
+				ExpectNonEmptyPlan: true, // every plan rotates the hash
+				Check: func(state *terraform.State) error {
+					hash = getResourceAttr(state, "stateful_string.object", "hash")
+					return testResourceAttrDoesNotEqual("stateful_string.object", "hash", strPtr(getSHA256("foo")))(state)
+				},
+			},
+			{
+				Config:             templateEphemeral, // consecutive apply of the same config
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrDoesNotEqual("stateful_string.object", "hash", &hash),
+					testResourceAttrEquals("stateful_string.object", "hash_changed", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateNumericKeys = `
+resource "stateful_map" "object" {
+  desired      = {
+    "10" = "a"
+    "2"  = "b"
+    "x"  = "c"
+  }
+  numeric_keys = %t
+}
+`
+
+func TestStatefulMapNumericKeys(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateNumericKeys, false),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256(map[string]string{"10": "a", "2": "b", "x": "c"}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateNumericKeys, true), // "2" precedes "10", non-numeric keys follow
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256([][]string{{"2", "b"}, {"10", "a"}, {"x", "c"}}))),
+				),
+			},
+		},
+	})
+}
+
+const templateOrderedKeys = `
+resource "stateful_map" "object" {
+  desired      = {
+    a = "1"
+    b = "2"
+  }
+  ordered_keys = [%s]
+}
+`
+
+func TestStatefulMapOrderedKeys(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateOrderedKeys, `"a", "b"`),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256([][]string{{"a", "1"}, {"b", "2"}}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateOrderedKeys, `"b", "a"`), // different order yields different hash
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256([][]string{{"b", "2"}, {"a", "1"}}))),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateOrderedKeys, `"b"`), // unlisted keys follow the listed ones
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256([][]string{{"b", "2"}, {"a", "1"}}))),
+				),
+			},
+		},
+	})
+}
+
+func TestStatefulStringHashUrlencoded(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: getConfig("foo", "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash_urlencoded", strPtr(url.QueryEscape(getSHA256("foo")))),
+					// percent-encoded value round-trips to the hash
+					func(state *terraform.State) error {
+						encoded := getResourceAttr(state, "stateful_string.object", "hash_urlencoded")
+						decoded, err := url.QueryUnescape(encoded)
+						if err != nil {
+							return err
+						}
+						if decoded != getResourceAttr(state, "stateful_string.object", "hash") {
+							return fmt.Errorf("'%s' does not decode to the hash", encoded)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+const templateSimilarityThreshold = `
+resource "stateful_string" "object" {
+  desired              = "hello world"
+  real                 = "hello wrold"
+  similarity_threshold = %g
+}
+`
+
+func TestStatefulStringSimilarityThreshold(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateSimilarityThreshold, 1.5),
+				ExpectError: regexp.MustCompile("must be greater than 0 and not greater than 1"),
+			},
+			{
+				Config:             fmt.Sprintf(templateSimilarityThreshold, 0.8), // similarity is 1-2/11, above threshold
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("hello world"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "diff", strPtr("")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateSimilarityThreshold, 0.9), // below threshold
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateDiffbase = `
+resource "stateful_map" "object" {
+  desired = {
+    key = "%s"
+  }
+}
+`
+
+func TestStatefulMapDiffbase(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateDiffbase, "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "diffbase.key", strPtr("foo")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateDiffbase, "bar"), // hash is recomputed
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256(map[string]string{"key": "bar"}))),
+					testResourceAttrEquals("stateful_map.object", "diffbase.key", strPtr("bar")),
+				),
+			},
+		},
+	})
+}
+
+const templateRequireReal = `
+resource "stateful_string" "object" {
+  desired      = "foo"
+  require_real = true
+  %s
+}
+`
+
+func TestStatefulStringRequireReal(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateRequireReal, ""), // real is missing
+				ExpectError: regexp.MustCompile("'real' of resource '' is not set while 'require_real' is enabled"),
+			},
+			{
+				Config: fmt.Sprintf(templateRequireReal, `real = "foo"`),
+			},
+			{
+				Config: fmt.Sprintf(templateRequireReal, `observe_command = ["echo", "foo"]`), // real is reported by command
+			},
+		},
+	})
+}
+
+const templateLockToken = `
+resource "stateful_string" "first" {
+  desired = "%s"
+}
+resource "stateful_string" "second" {
+  desired = "%s"
+}
+`
+
+func TestStatefulStringLockToken(t *testing.T) {
+	lockToken := new(string)
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateLockToken, "foo", "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					func(state *terraform.State) error {
+						*lockToken = getResourceAttr(state, "stateful_string.first", "lock_token")
+						id := getResourceAttr(state, "stateful_string.first", "id")
+						if *lockToken != getLockToken(id, getSHA256("foo")) {
+							return fmt.Errorf("lock token '%s' is not derived from the id and the hash", *lockToken)
+						}
+						return nil
+					},
+					// identical content of another instance yields a different token
+					testResourceAttrDoesNotEqual("stateful_string.second", "lock_token", lockToken),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateLockToken, "foo", "bar"), // no-op for the first instance
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.first", "lock_token", lockToken),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateLockToken, "baz", "bar"), // content changed
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrDoesNotEqual("stateful_string.first", "lock_token", lockToken),
+				),
+			},
+		},
+	})
+}
+
+const templateHashChanged = `
+resource "stateful_string" "object" {
+  desired    = "%s"
+  group_size = %d
+}
+`
+
+func TestStatefulStringHashChanged(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateHashChanged, "foo", 8), // create
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash_changed", strPtr("true")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateHashChanged, "foo", 16), // update that does not change the hash
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash_changed", strPtr("false")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateHashChanged, "bar", 16), // update that changes the hash
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash_changed", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateValuesMultiset = `
+resource "stateful_map" "object" {
+  desired         = {
+    a = "x"
+    b = "y"
+  }
+  real            = {
+    p = "y"
+    q = "%s"
+  }
+  values_multiset = true
+}
+`
+
+func TestStatefulMapValuesMultiset(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateValuesMultiset, "x"), // same values under different keys
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256([]string{"x", "y"}))),
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateValuesMultiset, "y"), // multiplicity differs
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateCanonicalJsonValues = `
+resource "stateful_map" "object" {
+  desired = {
+    a = "{\"y\": 2, \"x\": 1}"
+    b = "not json"
+  }
+  real = {
+    a = "%s"
+    b = "not json"
+  }
+  canonical_json_values = true
+}
+`
+
+func TestStatefulMapCanonicalJsonValues(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateCanonicalJsonValues, `{\"x\":1,\"y\":2}`), // key order differs
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256(map[string]string{"a": `{"x":1,"y":2}`, "b": "not json"}))),
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateCanonicalJsonValues, `{\"x\":1,\"y\":3}`),
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+			},
+		},
+	})
+}
+
+const templateCaseInsensitive = `
+resource "stateful_string" "object" {
+  desired          = "%s"
+  real             = "%s"
+  case_insensitive = true
+}
+`
+
+func TestStatefulStringCaseInsensitive(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateCaseInsensitive, "Example.COM", "example.com"),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("example.com"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateCaseInsensitive, "example.com", "EXAMPLE.com"), // hash is stable
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("example.com"))),
+			},
+			{
+				Config:             fmt.Sprintf(templateCaseInsensitive, "example.com", "example.org"),
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+const templateMerge = `
+resource "stateful_map" "object" {
+  desired = {
+    a = "1"
+    b = ""
+  }
+  real  = {%s}
+  merge = true
+}
+`
+
+func TestStatefulMapMerge(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateMerge, `a = "1", b = "", c = "unmanaged"`), // extra keys in real
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", strPtr(getSHA256(map[string]string{"a": "1", "b": ""}))),
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateMerge, `a = "1", c = "unmanaged"`), // key with empty value is missing
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+			},
+			{
+				Config:             fmt.Sprintf(templateMerge, `a = "2", b = ""`),
+				ExpectNonEmptyPlan: true,
+				Check:              testResourceAttrEquals("stateful_map.object", "drifted", strPtr("true")),
+			},
+		},
+	})
+}
+
+const templateIgnoreKeys = `
+resource "stateful_map" "object" {
+  desired = {
+    a    = "1"
+    etag = "%s"
+  }
+  real = {
+    a         = "1"
+    etag      = "%s"
+    last_seen = "%s"
+  }
+  ignore_keys = ["etag", "last_seen", "missing"]
+}
+`
+
+func TestStatefulMapIgnoreKeys(t *testing.T) {
+	hash := getSHA256(map[string]string{"a": "1"})
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateIgnoreKeys, "x", "y", "monday"),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", &hash),
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateIgnoreKeys, "z", "y", "tuesday"), // only ignored keys differ
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "hash", &hash),
+					testResourceAttrEquals("stateful_map.object", "drifted", strPtr("false")),
+				),
+			},
+		},
+	})
+}
+
+const templateValuePatterns = `
+resource "stateful_map" "object" {
+  desired = {
+    port = "%s"
+    name = "web"
+  }
+  value_patterns = {
+    port    = "%s"
+    missing = "^$"
+  }
+}
+`
+
+func TestStatefulMapValuePatterns(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateValuePatterns, "80", "[0-9"),
+				ExpectError: regexp.MustCompile("value_patterns: invalid pattern for key 'port'"),
+			},
+			{
+				Config:      fmt.Sprintf(templateValuePatterns, "http", "^[0-9]+$"),
+				ExpectError: regexp.MustCompile(`value 'http' of key 'port' of 'desired' must match '\^\[0-9\]\+\$' as per 'value_patterns'`),
+			},
+			{
+				Config: fmt.Sprintf(templateValuePatterns, "80", "^[0-9]+$"),
+				Check:  testResourceAttrEquals("stateful_map.object", "desired.port", strPtr("80")),
+			},
+		},
+	})
+}
+
+const templateTransforms = `
+resource "stateful_string" "object" {
+  desired    = "%s"
+  real       = "%s"
+  transforms = [%s]
+}
+`
+
+func TestStatefulStringTransforms(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateTransforms, "foo", "foo", `"unknown"`),
+				ExpectError: regexp.MustCompile("unsupported transform 'unknown'"),
+			},
+			{
+				Config:             fmt.Sprintf(templateTransforms, " Foo ", "foo", `"trim", "lower"`),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				// parameters are sorted before they are lowercased
+				Config:             fmt.Sprintf(templateTransforms, "B=1&a=2", "B=1&a=2", `"querystring", "lower"`),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("b=1&a=2"))),
+				),
+			},
+			{
+				// parameters are lowercased before they are sorted
+				Config:             fmt.Sprintf(templateTransforms, "B=1&a=2", "B=1&a=2", `"lower", "querystring"`),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("a=2&b=1"))),
+				),
+			},
+		},
+	})
+}
+
+const templateChangeReport = `
+resource "stateful_map" "object" {
+  desired = {
+    %s
+  }
+}
+`
+
+func TestStatefulMapChangeReport(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateChangeReport, `a = "1"
+    b = "2"
+    c = "3"`),
+			},
+			{
+				Config: fmt.Sprintf(templateChangeReport, `a = "1"
+    b = "20"
+    d = "4"`), // b changed, c removed and d added
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_map.object", "change_report", strPtr(
+						"b: \"2\" -> \"20\"\nc: \"3\" -> (absent)\nd: (absent) -> \"4\"\n",
+					)),
+				),
+			},
+		},
+	})
+}
+
 func strPtr(t string) *string {
 	return &t
 }
 
+func TestCanonicalizeHeaders(t *testing.T) {
+	cases := map[string]string{
+		"Subject: foo\nFrom: bar\n":              "From: bar\nSubject: foo\n",
+		"subject: foo\n bar\nX-Tag: a\nX-Tag: b": "Subject: foo bar\nX-Tag: a\nX-Tag: b\n",
+		"Subject: foo\r\n\tbar\r\n":              "Subject: foo bar\n",
+		"Subject: foo\n\nbody":                   "Subject: foo\n\nbody", // bodies are not supported
+		"not a header":                           "not a header",
+	}
+	for input, expected := range cases {
+		if actual := canonicalizeHeaders(input); actual != expected {
+			t.Errorf("canonicalizeHeaders(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+const templateHeaders = `
+resource "stateful_string" "object" {
+  desired       = "%s"
+  real          = "%s"
+  headers_value = true
+}
+`
+
+func TestStatefulStringHeaders(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				// same headers, folded and in a different order
+				Config:             fmt.Sprintf(templateHeaders, `Subject: foo bar\nFrom: baz`, `from: baz\nSubject: foo\n  bar`),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("From: baz\nSubject: foo bar\n"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateHeaders, `Subject: foo bar\nFrom: baz`, `From: qux\nSubject: foo bar`), // value differs
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateRealIsList = `
+resource "stateful_string" "object" {
+  desired        = "x"
+  real           = jsonencode(%s)
+  match_any_real = true
+}
+`
+
+func TestStatefulStringRealIsList(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateRealIsList, `["a", "x", "b"]`), // desired is among observed values
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("x"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateRealIsList, `["a", "b"]`), // desired is not observed
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}
+
+const templateEffectiveConfig = `
+resource "stateful_string" "object" {
+  desired           = "foo"
+  querystring_value = true
+  %s
+}
+`
+
+func TestStatefulStringEffectiveConfig(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateEffectiveConfig, ""), // implied by individual options
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "effective_config.algorithm", strPtr("sha256")),
+					testResourceAttrEquals("stateful_string.object", "effective_config.serialization", strPtr("json")),
+					testResourceAttrEquals("stateful_string.object", "effective_config.hmac_key_present", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "effective_config.transforms", strPtr("querystring")),
+					testResourceAttrEquals("stateful_string.object", "effective_config.transforms_source", strPtr("options")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateEffectiveConfig, `transforms = ["trim", "lower"]`), // overridden
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "effective_config.transforms", strPtr("trim,lower")),
+					testResourceAttrEquals("stateful_string.object", "effective_config.transforms_source", strPtr("transforms")),
+				),
+			},
+		},
+	})
+}
+
+func TestRefreshFingerprint(t *testing.T) {
+	config := &Config{Serialization: SerializationJson, HashAlgorithm: HashAlgorithmSha256, HashEncoding: HashEncodingHex}
+	d := resourceStatefulString().Data(&terraform.InstanceState{ID: "id", Attributes: map[string]string{"desired": "foo"}})
+	refreshFingerprint(d, config)
+	// Stored hash is only reused as long as it still matches the state, so it's the changes tracked along with it that
+	// tell whether it's reused or recomputed
+	stored := d.Get(FieldHash).(string)
+	state := d.State()
+	state.Attributes[FieldLastChanged] = "2019-01-01T00:00:00Z"
+
+	d = resourceStatefulString().Data(state)
+	refreshFingerprint(d, config)
+	if hash := d.Get(FieldHash); hash != stored {
+		t.Errorf("hash '%s' should be reused", hash)
+	}
+	if changed := d.Get(FieldLastChanged); changed != "2019-01-01T00:00:00Z" || d.Get(FieldGeneration) != 1 {
+		t.Errorf("reused hash should not be tracked as changed, got last_changed '%s'", changed)
+	}
+	if token := d.Get(FieldLockToken); token != getLockToken("id", stored) {
+		t.Errorf("lock_token '%s' should be derived from the stored hash", token)
+	}
+
+	d = resourceStatefulString().Data(state)
+	cbor := *config
+	cbor.Serialization = SerializationCbor
+	refreshFingerprint(d, &cbor)
+	if hash := d.Get(FieldHash); hash == stored {
+		t.Errorf("hash should be recomputed once serialization changes")
+	}
+}
+
+func TestRefreshFingerprintHashCheck(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	config := &Config{Serialization: SerializationJson, HashAlgorithm: HashAlgorithmSha256, HashEncoding: HashEncodingHex}
+	d := resourceStatefulString().Data(&terraform.InstanceState{ID: "id", Attributes: map[string]string{"desired": "foo"}})
+	if err := refreshFingerprint(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Hash doesn't match desired value, e.g. the state was edited by hand
+	state := d.State()
+	state.Attributes[FieldHash] = "tampered"
+	state.Attributes[FieldLastChanged] = "2019-01-01T00:00:00Z"
+	state.Attributes[FieldDiffbase] = "bar"
+
+	d = resourceStatefulString().Data(state)
+	if err := refreshFingerprint(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if hash := d.Get(FieldHash); hash != getSHA256("foo") {
+		t.Errorf("hash '%s' should be repaired", hash)
+	}
+	// Repaired hash is not tracked as a change of it
+	for attribute, expected := range map[string]interface{}{
+		FieldGeneration:  1,
+		FieldLastChanged: "2019-01-01T00:00:00Z",
+		FieldOldHash:     "",
+		FieldDiffbase:    "bar",
+	} {
+		if actual := d.Get(attribute); actual != expected {
+			t.Errorf("'%s' should stay '%v' upon repair, got '%v'", attribute, expected, actual)
+		}
+	}
+	expected := fmt.Sprintf("[WARN] stateful: repairing hash 'tampered' of resource 'id' as '%s'", getSHA256("foo"))
+	if !strings.Contains(output.String(), expected) {
+		t.Errorf("log output '%s' does not contain '%s'", output.String(), expected)
+	}
+
+	d = resourceStatefulString().Data(state)
+	strict := *config
+	strict.StrictHashCheck = true
+	err := refreshFingerprint(d, &strict)
+	expected = fmt.Sprintf("hash 'tampered' of resource 'id' doesn't match '%s' derived from its state", getSHA256("foo"))
+	if err == nil || err.Error() != expected {
+		t.Errorf("error '%v' should be '%s'", err, expected)
+	}
+}
+
+func TestStateUpgradeV0(t *testing.T) {
+	// State written before the schema was versioned, lacking all the attributes introduced since
+	state := &terraform.InstanceState{
+		ID: "id",
+		Attributes: map[string]string{
+			"id":            "id",
+			"desired":       "foo",
+			"hash":          getSHA256("foo"),
+			"last_changed":  "2019-01-01T00:00:00Z",
+			"serialization": SerializationJson,
+		},
+	}
+
+	upgraded, err := resourceStatefulString().Refresh(state, newConfig())
+	if err != nil {
+		t.Fatalf("upgrade should succeed: %s", err)
+	}
+	for attribute, expected := range map[string]string{
+		FieldHash:        getSHA256("foo"),
+		FieldGeneration:  "1",
+		FieldLastUpdated: "2019-01-01T00:00:00Z",
+		FieldIdSource:    IdSourceRandomV4,
+		FieldHashSha256:  getSHA256("foo"),
+		FieldLastChanged: "2019-01-01T00:00:00Z",
+		FieldHashChanged: "false",
+		FieldHashSource:  HashSourceDesired,
+	} {
+		if actual := upgraded.Attributes[attribute]; actual != expected {
+			t.Errorf("'%s' should be '%s' after the upgrade, got '%s'", attribute, expected, actual)
+		}
+	}
+	if version := upgraded.Meta["schema_version"]; version != "1" {
+		t.Errorf("schema version should be recorded, got '%v'", version)
+	}
+}
+
+func TestLogDrift(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	d := resourceStatefulString().Data(&terraform.InstanceState{
+		ID:         "id",
+		Attributes: map[string]string{"desired": "foo", "hash": "stored"},
+	})
+	logDrift(d, newConfig(), "observed")
+	expected := "[WARN] stateful: real state of resource 'id' diverges from the desired one (hash 'stored', real_hash 'observed')"
+	if !strings.Contains(output.String(), expected) {
+		t.Errorf("log output '%s' does not contain '%s'", output.String(), expected)
+	}
+
+	output.Reset()
+	logDrift(d, &Config{WarnOnDrift: false}, "observed")
+	if output.Len() != 0 {
+		t.Errorf("log output '%s' should be empty once warnings are disabled", output.String())
+	}
+}
+
+func TestDebugLog(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	d := resourceStatefulString().Data(&terraform.InstanceState{
+		ID:         "id",
+		Attributes: map[string]string{"desired": "foo", "debug_log": "true"},
+	})
+	hash := getStatefulResourceFingerprint(d, newConfig())
+	expected := fmt.Sprintf(`[DEBUG] stateful: resource 'id' hashed json "\"foo\"" as '%s'`, hash)
+	if !strings.Contains(output.String(), expected) {
+		t.Errorf("log output '%s' does not contain '%s'", output.String(), expected)
+	}
+
+	output.Reset()
+	_, public := generateEncryptionKey(t)
+	d.Set(FieldEncryptPublicKey, public)
+	getStatefulResourceFingerprint(d, newConfig())
+	if !strings.Contains(output.String(), "(redacted)") || strings.Contains(output.String(), "foo") {
+		t.Errorf("log output '%s' should be redacted", output.String())
+	}
+}
+
+// BenchmarkReadResource compares refreshes of many resources that reuse stored hashes with ones that recompute them
+func BenchmarkReadResource(b *testing.B) {
+	desired := strings.Repeat("foo bar baz\n", 1024)
+	config := newConfig()
+	res := resourceStatefulString()
+
+	// A refreshed state records everything needed to reuse the hash
+	d := res.Data(&terraform.InstanceState{ID: "id", Attributes: map[string]string{"desired": desired}})
+	if err := readResource(d, config); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	for name, attributes := range map[string]map[string]string{
+		"reuse":     d.State().Attributes,
+		"recompute": {"desired": desired},
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < 1000; j++ {
+					d := res.Data(&terraform.InstanceState{ID: strconv.Itoa(j), Attributes: attributes})
+					if err := readResource(d, config); err != nil {
+						b.Fatalf("err: %s", err)
+					}
+				}
+			}
+		})
+	}
+}
+
 func getResourceAttr(state *terraform.State, resource string, attr string) string {
 	return state.RootModule().Resources[resource].Primary.Attributes[attr]
 }
@@ -106,3 +2537,50 @@ func testResourceAttrDoesNotEqual(resource string, attr string, expected *string
 		return nil
 	}
 }
+
+func TestNormalizeWhitespace(t *testing.T) {
+	cases := map[string]string{
+		"a  \r\nb\t\r\n":  "a\nb\n",
+		"a\rb":            "a\nb",
+		" a \n  b":        " a\n  b", // leading whitespace is significant
+		" \t\r\n  \n":     "",
+		"":                "",
+		"line\n\n  \nend": "line\n\n\nend",
+	}
+	for input, expected := range cases {
+		if actual := normalizeWhitespace(input); actual != expected {
+			t.Errorf("normalizeWhitespace(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+const templateNormalizeWhitespace = `
+resource "stateful_string" "object" {
+  desired              = "%s"
+  real                 = "%s"
+  normalize_whitespace = true
+  case_insensitive     = true
+}
+`
+
+func TestStatefulStringNormalizeWhitespace(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:             fmt.Sprintf(templateNormalizeWhitespace, `Key = 1  \r\nOther = 2\n`, `key = 1\nother = 2 \n`),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("key = 1\nother = 2\n"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateNormalizeWhitespace, ` \n\t`, ""), // whitespace only
+				ExpectNonEmptyPlan: false,
+				Check:              testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(""))),
+			},
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package stateful
+
+import (
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/satori/go.uuid"
+)
+
+const FieldExpiresAt = "expires_at"
+
+func resourceStatefulTimestamp() *schema.Resource {
+	return &schema.Resource{
+		Create: createTimestamp,
+		Read:   readTimestamp,
+		Update: updateTimestamp,
+		Delete: deleteResource,
+
+		CustomizeDiff: diffTimestamp,
+
+		Schema: map[string]*schema.Schema{
+			// "Inputs"
+			FieldTtl: {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateDuration,
+			},
+			// "Outputs"
+			FieldExpiresAt: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			FieldHash: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// setExpiry starts a new window of ttl from now along with a new hash derived from the time it expires at
+func setExpiry(d *schema.ResourceData, m interface{}) {
+	ttl, _ := time.ParseDuration(d.Get(FieldTtl).(string))
+	expiresAt := timeNow().Add(ttl).UTC().Format(time.RFC3339)
+	d.Set(FieldExpiresAt, expiresAt)
+	d.Set(FieldHash, getConfiguredDigest([]byte(expiresAt), m))
+}
+
+func createTimestamp(d *schema.ResourceData, m interface{}) error {
+	d.SetId(uuid.NewV4().String())
+	setExpiry(d, m)
+	return nil
+}
+
+// readTimestamp rolls the hash once the window expires so that dependents are updated upon the next apply, the hash
+// stays the same until then
+func readTimestamp(d *schema.ResourceData, m interface{}) error {
+	expiresAt, err := time.Parse(time.RFC3339, d.Get(FieldExpiresAt).(string))
+	if err != nil || !timeNow().Before(expiresAt) {
+		setExpiry(d, m)
+	}
+	return nil
+}
+
+func updateTimestamp(d *schema.ResourceData, m interface{}) error {
+	setExpiry(d, m)
+	return nil
+}
+
+// diffTimestamp restarts the window whenever ttl changes
+func diffTimestamp(d *schema.ResourceDiff, m interface{}) error {
+	if d.HasChange(FieldTtl) {
+		d.SetNewComputed(FieldExpiresAt)
+		d.SetNewComputed(FieldHash)
+	}
+	return nil
+}
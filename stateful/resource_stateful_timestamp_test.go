@@ -0,0 +1,67 @@
+package stateful
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateTimestamp = `
+resource "stateful_timestamp" "object" {
+  ttl = "%s"
+}
+`
+
+func TestStatefulTimestamp(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	hash := func(expiresAt string) *string {
+		return strPtr(getDigest([]byte(expiresAt)))
+	}
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(templateTimestamp, "1 hour"),
+				ExpectError: regexp.MustCompile("'ttl' must be a valid duration"),
+			},
+			{
+				Config: fmt.Sprintf(templateTimestamp, "1h"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_timestamp.object", "expires_at", strPtr("2019-06-01T01:00:00Z")),
+					testResourceAttrEquals("stateful_timestamp.object", "hash", hash("2019-06-01T01:00:00Z")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(30 * time.Minute) }, // not expired yet
+				Config:    fmt.Sprintf(templateTimestamp, "1h"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_timestamp.object", "expires_at", strPtr("2019-06-01T01:00:00Z")),
+					testResourceAttrEquals("stateful_timestamp.object", "hash", hash("2019-06-01T01:00:00Z")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(time.Hour) }, // expired
+				Config:    fmt.Sprintf(templateTimestamp, "1h"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_timestamp.object", "expires_at", strPtr("2019-06-01T02:30:00Z")),
+					testResourceAttrEquals("stateful_timestamp.object", "hash", hash("2019-06-01T02:30:00Z")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateTimestamp, "2h"), // window restarts
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_timestamp.object", "expires_at", strPtr("2019-06-01T03:30:00Z")),
+					testResourceAttrEquals("stateful_timestamp.object", "hash", hash("2019-06-01T03:30:00Z")),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,88 @@
+package stateful
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// timeNow is a variable so that tests can stub the clock
+var timeNow = time.Now
+
+// isRotationDue checks whether ttl has elapsed since the hash changed last time
+func isRotationDue(d *schema.ResourceData) (bool, error) {
+	ttl, ok := d.GetOk(FieldTtl)
+	if !ok {
+		return false, nil
+	}
+	lastChanged, ok := d.GetOk(FieldLastChanged)
+	if !ok {
+		return false, nil
+	}
+
+	duration, err := time.ParseDuration(ttl.(string))
+	if err != nil {
+		return false, err
+	}
+	changedAt, err := time.Parse(time.RFC3339, lastChanged.(string))
+	if err != nil {
+		return false, fmt.Errorf("cannot parse '%s': %s", FieldLastChanged, err)
+	}
+
+	return timeNow().Sub(changedAt) > duration, nil
+}
+
+// getAge formats the time elapsed since the hash changed last time as a duration with trailing zero units dropped,
+// e.g. "3h20m"
+func getAge(d resourceGetter) string {
+	changedAt, err := time.Parse(time.RFC3339, d.Get(FieldLastChanged).(string))
+	if err != nil {
+		return ""
+	}
+	return formatDuration(timeNow().Sub(changedAt))
+}
+
+// formatDuration renders the duration truncated to seconds without trailing zero units, e.g. "3h20m"
+func formatDuration(duration time.Duration) string {
+	formatted := duration.Truncate(time.Second).String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
+	}
+	return formatted
+}
+
+// getRotationSchedule computes when the next rotation is due as an RFC 3339 timestamp along with whether it's due
+// already, both are empty unless rotation period is set
+func getRotationSchedule(d resourceGetter) (string, bool) {
+	period, ok := d.GetOk(FieldRotationPeriod)
+	if !ok {
+		return "", false
+	}
+	duration, err := time.ParseDuration(period.(string))
+	if err != nil {
+		return "", false
+	}
+	changedAt, err := time.Parse(time.RFC3339, d.Get(FieldLastChanged).(string))
+	if err != nil {
+		return "", false
+	}
+
+	nextRotation := changedAt.Add(duration)
+	return nextRotation.UTC().Format(time.RFC3339), !timeNow().Before(nextRotation)
+}
+
+func validateDuration(v interface{}, k string) ([]string, []error) {
+	duration, err := time.ParseDuration(v.(string))
+	if err != nil {
+		return nil, []error{fmt.Errorf("'%s' must be a valid duration, e.g. '24h': %s", k, err)}
+	}
+	if duration <= 0 {
+		return nil, []error{fmt.Errorf("'%s' must be positive, got %s", k, v.(string))}
+	}
+	return nil, nil
+}
@@ -0,0 +1,199 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const templateTtl = `
+resource "stateful_string" "object" {
+  desired = "foo"
+  ttl     = "1h"
+}
+`
+
+func TestStatefulStringTtl(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	hash := getSHA256("foo")
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateTtl,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(hash)),
+					testResourceAttrEquals("stateful_string.object", "last_changed", strPtr("2019-06-01T00:00:00Z")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(30 * time.Minute) }, // ttl has not elapsed yet
+				Config:    templateTtl,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(hash)),
+					testResourceAttrEquals("stateful_string.object", "last_changed", strPtr("2019-06-01T00:00:00Z")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(time.Hour) }, // ttl elapsed -> hash is rotated
+				Config:    templateTtl,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrDoesNotEqual("stateful_string.object", "hash", strPtr(hash)),
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(map[string]interface{}{"desired": "foo", "rotation_count": 1}))),
+					testResourceAttrEquals("stateful_string.object", "last_changed", strPtr("2019-06-01T01:30:00Z")),
+				),
+			},
+		},
+	})
+}
+
+const templateAge = `
+resource "stateful_string" "object" {
+  desired = "foo"
+}
+`
+
+func TestStatefulStringAge(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateAge,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "age", strPtr("0s")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(3*time.Hour + 20*time.Minute) }, // refresh re-evaluates the age
+				Config:    templateAge,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "last_changed", strPtr("2019-06-01T00:00:00Z")),
+					testResourceAttrEquals("stateful_string.object", "age", strPtr("3h20m")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(time.Hour + 5*time.Second) },
+				Config:    templateAge,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "age", strPtr("4h20m5s")),
+				),
+			},
+		},
+	})
+}
+
+const templateRotationPeriod = `
+resource "stateful_string" "object" {
+  desired         = "foo"
+  rotation_period = "%s"
+}
+`
+
+func TestStatefulStringRotationPeriod(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateRotationPeriod, "24h"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "next_rotation", strPtr("2019-06-02T00:00:00Z")),
+					testResourceAttrEquals("stateful_string.object", "rotation_due", strPtr("false")),
+				),
+			},
+			{
+				PreConfig: func() { now = now.Add(24 * time.Hour) }, // rotation is due, hash is not rotated automatically
+				Config:    fmt.Sprintf(templateRotationPeriod, "24h"),
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "next_rotation", strPtr("2019-06-02T00:00:00Z")),
+					testResourceAttrEquals("stateful_string.object", "rotation_due", strPtr("true")),
+				),
+			},
+			{
+				Config: fmt.Sprintf(templateRotationPeriod, "48h"), // period extended
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "next_rotation", strPtr("2019-06-03T00:00:00Z")),
+					testResourceAttrEquals("stateful_string.object", "rotation_due", strPtr("false")),
+				),
+			},
+		},
+	})
+}
+
+const templateDriftGracePeriod = `
+resource "stateful_string" "object" {
+  desired            = "foo"
+  real               = "%s"
+  drift_grace_period = "1h"
+}
+`
+
+func TestStatefulStringDriftGracePeriod(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(templateDriftGracePeriod, "foo"), // in sync
+			},
+			{
+				Config:             fmt.Sprintf(templateDriftGracePeriod, "bar"), // drift is observed
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256("foo"))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "drift_pending", strPtr("true")),
+					testResourceAttrEquals("stateful_string.object", "drift_first_observed", strPtr("2019-06-01T00:00:00Z")),
+				),
+			},
+			{
+				PreConfig:          func() { now = now.Add(30 * time.Minute) }, // drift persists within the grace period
+				Config:             fmt.Sprintf(templateDriftGracePeriod, "bar"),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "drift_first_observed", strPtr("2019-06-01T00:00:00Z")),
+				),
+			},
+			{
+				PreConfig:          func() { now = now.Add(time.Hour) }, // drift persists beyond the grace period
+				Config:             fmt.Sprintf(templateDriftGracePeriod, "bar"),
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+					testResourceAttrEquals("stateful_string.object", "drift_pending", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateDriftGracePeriod, "foo"), // drift is gone
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "drift_pending", strPtr("false")),
+					testResourceAttrEquals("stateful_string.object", "drift_first_observed", strPtr("")),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,20 @@
+package stateful
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// getToken produces a self-describing token of the form base64(hash).base64(hmac(hash)) so that consumers holding the
+// key can verify integrity of the hash without access to the plaintext
+func getToken(hash string, key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(hash)) + "." +
+		base64.RawURLEncoding.EncodeToString(getHMAC([]byte(hash), key))
+}
+
+func getHMAC(data []byte, key string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
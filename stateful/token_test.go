@@ -0,0 +1,65 @@
+package stateful
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const templateSignToken = `
+provider "stateful" {
+  hmac_key = "secret"
+}
+resource "stateful_string" "object" {
+  desired    = "foo"
+  sign_token = true
+}
+`
+
+func TestStatefulStringSignToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: templateSignToken,
+				Check: resource.ComposeTestCheckFunc(
+					// The hash itself is keyed with the HMAC key as well
+					testResourceAttrEquals("stateful_string.object", "token",
+						strPtr(getToken(fmt.Sprintf("%x", getHMAC([]byte(`"foo"`), "secret")), "secret"))),
+					func(state *terraform.State) error {
+						token := getResourceAttr(state, "stateful_string.object", "token")
+						if !verifyToken(token, "secret") {
+							return fmt.Errorf("token '%s' should verify with the configured key", token)
+						}
+						if verifyToken(token, "wrong") {
+							return fmt.Errorf("token '%s' should not verify with a wrong key", token)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func verifyToken(token string, key string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return false
+	}
+	hash, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, getHMAC(hash, key))
+}
@@ -0,0 +1,479 @@
+package stateful
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// canonicalizeToml re-encodes a TOML document as JSON with keys sorted so that formatting, comments and key order
+// don't matter, values that cannot be parsed as TOML are returned untouched
+func canonicalizeToml(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	document, err := parseToml(s)
+	if err != nil {
+		return value
+	}
+	serialized, err := json.Marshal(document)
+	if err != nil {
+		return value
+	}
+	return string(serialized)
+}
+
+// tomlFloat tags floats in the canonical form so that they always differ from integers, e.g. 1.0 from 1
+type tomlFloat float64
+
+func (f tomlFloat) MarshalJSON() ([]byte, error) {
+	s := strconv.FormatFloat(float64(f), 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return []byte(s), nil
+}
+
+// tomlDateTime tags date-times in the canonical form so that they always differ from strings of the same text. They
+// are encoded as arrays led by null, which TOML lacks, so that they cannot collide with any other value either.
+type tomlDateTime string
+
+func (t tomlDateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{nil, string(t)})
+}
+
+// tomlParser is a minimal recursive descent parser for TOML documents. It supports tables, arrays of tables, dotted
+// and quoted keys, all string flavors, integers, floats, booleans, arrays and inline tables. Date-times are kept as
+// tagged strings using 'T' as a separator.
+type tomlParser struct {
+	input string
+	pos   int
+}
+
+func parseToml(input string) (map[string]interface{}, error) {
+	p := &tomlParser{input: input}
+	root := map[string]interface{}{}
+	current := root
+
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseTableHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' && p.peek() != '\r' {
+			return nil, p.errorf("expected end of line")
+		}
+	}
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *tomlParser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *tomlParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.input[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.input[:p.pos], "\n") + 1
+	return fmt.Errorf("toml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank skips whitespace and comments, newlines are skipped as well only when requested
+func (p *tomlParser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case newlines && (c == '\n' || c == '\r'):
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) parseTableHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	isArray := p.consume("[[")
+	if !isArray {
+		p.consume("[")
+	}
+	path, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if isArray {
+		if !p.consume("]]") {
+			return nil, p.errorf("expected ']]'")
+		}
+		parent, err := p.getTable(root, path[:len(path)-1])
+		if err != nil {
+			return nil, err
+		}
+		last := path[len(path)-1]
+		tables, ok := parent[last].([]interface{})
+		if _, exists := parent[last]; exists && !ok {
+			return nil, p.errorf("key '%s' is already defined", last)
+		}
+		table := map[string]interface{}{}
+		parent[last] = append(tables, table)
+		return table, nil
+	}
+
+	if !p.consume("]") {
+		return nil, p.errorf("expected ']'")
+	}
+	return p.getTable(root, path)
+}
+
+// getTable walks the path creating missing tables, arrays of tables resolve to their last element
+func (p *tomlParser) getTable(table map[string]interface{}, path []string) (map[string]interface{}, error) {
+	for _, key := range path {
+		switch value := table[key].(type) {
+		case nil:
+			next := map[string]interface{}{}
+			table[key] = next
+			table = next
+		case map[string]interface{}:
+			table = value
+		case []interface{}:
+			if len(value) == 0 {
+				return nil, p.errorf("key '%s' is not a table", key)
+			}
+			last, ok := value[len(value)-1].(map[string]interface{})
+			if !ok {
+				return nil, p.errorf("key '%s' is not a table", key)
+			}
+			table = last
+		default:
+			return nil, p.errorf("key '%s' is not a table", key)
+		}
+	}
+	return table, nil
+}
+
+func (p *tomlParser) parseKeyValue(table map[string]interface{}) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if !p.consume("=") {
+		return p.errorf("expected '='")
+	}
+	p.skipBlank(false)
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	table, err = p.getTable(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	if _, exists := table[last]; exists {
+		return p.errorf("key '%s' is already defined", last)
+	}
+	table[last] = value
+	return nil
+}
+
+// parseKey parses a possibly dotted key along with the surrounding whitespace
+func (p *tomlParser) parseKey() ([]string, error) {
+	var path []string
+	for {
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("expected key")
+		}
+
+		var part string
+		var err error
+		switch p.peek() {
+		case '"':
+			part, err = p.parseBasicString()
+		case '\'':
+			part, err = p.parseLiteralString()
+		default:
+			start := p.pos
+			for !p.eof() && isTomlBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected key")
+			}
+			part = p.input[start:p.pos]
+		}
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, part)
+
+		p.skipBlank(false)
+		if !p.consume(".") {
+			return path, nil
+		}
+	}
+}
+
+func isTomlBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("expected value")
+	}
+	switch p.peek() {
+	case '"':
+		if strings.HasPrefix(p.input[p.pos:], `"""`) {
+			return p.parseMultilineString(`"""`, true)
+		}
+		return p.parseBasicString()
+	case '\'':
+		if strings.HasPrefix(p.input[p.pos:], `'''`) {
+			return p.parseMultilineString(`'''`, false)
+		}
+		return p.parseLiteralString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+		p.pos++
+	}
+	// Local date-times may use a space instead of 'T' as a separator
+	if p.pos-start == 10 && strings.Count(p.input[start:p.pos], "-") == 2 &&
+		p.pos+1 < len(p.input) && p.peek() == ' ' && p.input[p.pos+1] >= '0' && p.input[p.pos+1] <= '9' {
+		p.pos++
+		for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+			p.pos++
+		}
+	}
+	return p.parseScalar(p.input[start:p.pos])
+}
+
+func (p *tomlParser) parseScalar(token string) (interface{}, error) {
+	switch token {
+	case "":
+		return nil, p.errorf("expected value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return nil, p.errorf("non-finite float '%s' is not supported", token)
+	}
+
+	digits := strings.Replace(token, "_", "", -1)
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if strings.HasPrefix(digits, prefix) {
+			if i, err := strconv.ParseInt(digits[2:], base, 64); err == nil {
+				return i, nil
+			}
+			return nil, p.errorf("invalid integer '%s'", token)
+		}
+	}
+	if i, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(digits, 64); err == nil {
+		return tomlFloat(f), nil
+	}
+	if token[0] >= '0' && token[0] <= '9' && strings.ContainsAny(token, "-:") {
+		// Date-times are kept as is except for the separator between date and time
+		if len(token) > 10 && (token[10] == ' ' || token[10] == 't') {
+			token = token[:10] + "T" + token[11:]
+		}
+		return tomlDateTime(token), nil
+	}
+	return nil, p.errorf("invalid value '%s'", token)
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++ // opening quote
+	end := strings.IndexAny(p.input[p.pos:], "'\n")
+	if end < 0 || p.input[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.input[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+func (p *tomlParser) parseMultilineString(delimiter string, escapes bool) (string, error) {
+	p.pos += len(delimiter)
+	// A newline immediately following the opening delimiter is trimmed
+	if !p.consume("\n") {
+		p.consume("\r\n")
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if p.consume(delimiter) {
+			// Up to two quotes adjacent to the closing delimiter belong to the string
+			for i := 0; i < 2 && !p.eof() && p.peek() == delimiter[0]; i++ {
+				b.WriteByte(p.peek())
+				p.pos++
+			}
+			return b.String(), nil
+		}
+		if escapes && p.peek() == '\\' {
+			if rest := strings.TrimLeft(p.input[p.pos+1:], " \t"); strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				// Line ending backslash trims all whitespace up to the next non-whitespace character
+				p.pos = len(p.input) - len(strings.TrimLeft(rest, " \t\r\n"))
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(p.peek())
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	p.pos++ // backslash
+	if p.eof() {
+		return p.errorf("unterminated escape sequence")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.input) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.input[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape")
+		}
+		b.WriteRune(rune(code))
+		p.pos += size
+	default:
+		return p.errorf("invalid escape sequence '\\%c'", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++ // opening bracket
+	values := []interface{}{}
+	for {
+		p.skipBlank(true)
+		if p.consume("]") {
+			return values, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipBlank(true)
+		if p.consume("]") {
+			return values, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++ // opening brace
+	table := map[string]interface{}{}
+	p.skipBlank(false)
+	if p.consume("}") {
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if p.consume("}") {
+			return table, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or '}'")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package stateful
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestCanonicalizeToml(t *testing.T) {
+	cases := map[string]string{
+		`a = 1`:                        `{"a":1}`,
+		"b = 'x' # comment\na = \"y\"": `{"a":"y","b":"x"}`,
+		"[server]\nport = 8_080\nhosts = [\n  \"a\",\n  \"b\",\n]": `{"server":{"hosts":["a","b"],"port":8080}}`,
+		`server = { port = 0x1F90, tls = true }`:                   `{"server":{"port":8080,"tls":true}}`,
+		"server.ratio = 0.5\n\"quoted key\" = '''\nraw'''":         `{"quoted key":"raw","server":{"ratio":0.5}}`,
+		"[[item]]\nid = 1\n[[item]]\nid = 2":                       `{"item":[{"id":1},{"id":2}]}`,
+		`date = 1979-05-27 07:32:00Z`:                              `{"date":[null,"1979-05-27T07:32:00Z"]}`,
+		`date = 1979-05-27T07:32:00Z`:                              `{"date":[null,"1979-05-27T07:32:00Z"]}`,
+		`date = "1979-05-27T07:32:00Z"`:                            `{"date":"1979-05-27T07:32:00Z"}`,
+		"a = 1\nb = 1.0\nc = 1e3\nd = -0.25":                       `{"a":1,"b":1.0,"c":1000.0,"d":-0.25}`,
+		"[[a.b]]\nx = 1\n[[a.b]]\nx = 2\n[a.c]\ny = [{z = 3}]":     `{"a":{"b":[{"x":1},{"x":2}],"c":{"y":[{"z":3}]}}}`,
+		"a.b.c = 1\na.'d.e' = 2\n\"f\".g = 3":                      `{"a":{"b":{"c":1},"d.e":2},"f":{"g":3}}`,
+		"s = \"\"\"\nfoo \\\n   bar\nbaz\"\"\"\nt = '''\n\\n'''":   `{"s":"foo bar\nbaz","t":"\\n"}`,
+		"a = 1\na.b = 2":  "a = 1\na.b = 2",
+		"a = [1, 2":       "a = [1, 2",
+		"a = \"foo":       "a = \"foo",
+		"a = inf":         "a = inf",
+		`escaped = "é\t"`: `{"escaped":"é\t"}`,
+		`not toml`:        `not toml`,
+		"a = 1\na = 2":    "a = 1\na = 2",
+	}
+	for input, expected := range cases {
+		if actual := canonicalizeToml(input); actual != expected {
+			t.Errorf("canonicalizeToml(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+const templateToml = `
+resource "stateful_string" "object" {
+  desired    = <<EOT
+%s
+EOT
+  real       = <<EOT
+%s
+EOT
+  toml_value = true
+}
+`
+
+func TestStatefulStringToml(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testProviders,
+		Steps: []resource.TestStep{
+			{
+				// same structure, different formatting
+				Config:             fmt.Sprintf(templateToml, "[server]\nport = 8080\nname = \"web\"", "# reformatted\nserver = { name = 'web', port = 8_080 }"),
+				ExpectNonEmptyPlan: false,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "hash", strPtr(getSHA256(`{"server":{"name":"web","port":8080}}`))),
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("false")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateToml, "[server]\nport = 8080\nname = \"web\"", "server = { name = 'web', port = 8081 }"), // value differs
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+				),
+			},
+			{
+				Config:             fmt.Sprintf(templateToml, "date = 1979-05-27T07:32:00Z", "date = \"1979-05-27T07:32:00Z\""), // date-time vs string
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					testResourceAttrEquals("stateful_string.object", "drifted", strPtr("true")),
+				),
+			},
+		},
+	})
+}